@@ -0,0 +1,71 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// ● 端到端的Benchmark测试，包括 Context 的创建、middleware 链条和回写响应
+func BenchmarkHttpServer_ServeHTTP(b *testing.B) {
+	var mdlBuilder = func() Middleware {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				next(ctx)
+			}
+		}
+	}
+	server := NewHTTPServer(ServerWithMiddleware(mdlBuilder(), mdlBuilder(), mdlBuilder()))
+	server.Get("/user/home", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("hello")
+	})
+	server.Get("/user/:id", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte(ctx.PathParams["id"])
+	})
+
+	testCases := []struct {
+		name string
+		path string
+	}{
+		{
+			name: "static",
+			path: "/user/home",
+		},
+		{
+			name: "param",
+			path: "/user/123",
+		},
+		{
+			name: "not found",
+			path: "/order/123",
+		},
+	}
+	for _, tc := range testCases {
+		b.Run(tc.name, func(b *testing.B) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			resp := &discardWriter{header: http.Header{}}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				server.ServeHTTP(resp, req)
+			}
+		})
+	}
+}
+
+// discardWriter 避免 httptest.ResponseRecorder 自己的分配干扰测试结果
+type discardWriter struct {
+	header http.Header
+}
+
+func (d *discardWriter) Header() http.Header {
+	return d.header
+}
+
+func (d *discardWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (d *discardWriter) WriteHeader(int) {}
@@ -0,0 +1,413 @@
+package web
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 绑定使用的标签，同时也是 FieldError.Source 的取值
+const (
+	bindTagQuery  = "query"
+	bindTagForm   = "form"
+	bindTagPath   = "path"
+	bindTagHeader = "header"
+)
+
+var (
+	errBindTarget = errors.New("web: 绑定的目标必须是非 nil 的结构体指针")
+
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// BindQuery 按照 query 标签，用查询参数填充结构体
+// 例如 Page int `query:"page"`
+// 绑定成功之后会按照 validate 标签进行校验，下同
+func (c *Context) BindQuery(val any) error {
+	if err := bindStruct(val, bindTagQuery, c.querySource()); err != nil {
+		return err
+	}
+	return Validate(val)
+}
+
+// BindForm 按照 form 标签，用表单填充结构体
+// 和 FormValue 一样，用的是 Req.Form，也就是包含了查询参数
+// multipart/form-data 的请求体用 MultipartForm 解析，受 UploadLimits 的限制
+func (c *Context) BindForm(val any) error {
+	src, err := c.formSource()
+	if err != nil {
+		return err
+	}
+	if err = bindStruct(val, bindTagForm, src); err != nil {
+		return err
+	}
+	return Validate(val)
+}
+
+// BindPath 按照 path 标签，用路径参数填充结构体
+// 例如注册了 /user/:id，那么 Id int64 `path:"id"`
+func (c *Context) BindPath(val any) error {
+	if err := bindStruct(val, bindTagPath, c.pathSource()); err != nil {
+		return err
+	}
+	return Validate(val)
+}
+
+// BindHeader 按照 header 标签，用请求头填充结构体
+// 标签里的名字大小写不敏感，例如 Tenant string `header:"X-Tenant"`
+func (c *Context) BindHeader(val any) error {
+	if err := bindStruct(val, bindTagHeader, c.headerSource()); err != nil {
+		return err
+	}
+	return Validate(val)
+}
+
+// Bind 先按照 Content-Type 解析请求体，再依次从路径参数、查询参数、请求头和表单里面绑定
+// 表单类型的请求体不经过 Codec，由 form 标签绑定
+// 所有来源的字段错误会合并成一个 BindingErrors 返回
+// 全部绑定完成之后才会校验，所以 required 的字段可以来自任意一个来源
+func (c *Context) Bind(val any) error {
+	if err := checkBindTarget(val); err != nil {
+		return err
+	}
+	if !c.isFormBody() {
+		if err := c.decodeBody(val); err != nil {
+			return err
+		}
+	}
+	formSrc, err := c.formSource()
+	if err != nil {
+		return err
+	}
+	var errs BindingErrors
+	for _, s := range []struct {
+		tag string
+		src valueSource
+	}{
+		{tag: bindTagPath, src: c.pathSource()},
+		{tag: bindTagQuery, src: c.querySource()},
+		{tag: bindTagHeader, src: c.headerSource()},
+		{tag: bindTagForm, src: formSrc},
+	} {
+		if err = bindStruct(val, s.tag, s.src); err != nil {
+			errs = append(errs, err.(BindingErrors)...)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return Validate(val)
+}
+
+func (c *Context) isFormBody() bool {
+	mediaType := c.bodyMediaType()
+	return mediaType == MIMEForm || mediaType == MIMEMultipartForm
+}
+
+func (c *Context) bodyMediaType() string {
+	mediaType, _, _ := mime.ParseMediaType(c.Req.Header.Get("Content-Type"))
+	return mediaType
+}
+
+func (c *Context) querySource() valueSource {
+	values := c.query()
+	return func(key string) ([]string, bool) {
+		vals, ok := values[key]
+		return vals, ok
+	}
+}
+
+func (c *Context) formSource() (valueSource, error) {
+	// ParseForm 不解析 multipart 的请求体，和 Req.Form 一样，请求体里面的值在查询参数前面
+	if c.bodyMediaType() == MIMEMultipartForm {
+		form, err := c.MultipartForm(defaultMultipartMemory)
+		if err != nil {
+			return nil, err
+		}
+		query := c.query()
+		return func(key string) ([]string, bool) {
+			vals := form.Value[key]
+			vals = append(vals[:len(vals):len(vals)], query[key]...)
+			return vals, len(vals) > 0
+		}, nil
+	}
+	if err := c.Req.ParseForm(); err != nil {
+		return nil, err
+	}
+	return func(key string) ([]string, bool) {
+		vals, ok := c.Req.Form[key]
+		return vals, ok
+	}, nil
+}
+
+func (c *Context) pathSource() valueSource {
+	return func(key string) ([]string, bool) {
+		val, ok := c.PathParams[key]
+		if !ok {
+			return nil, false
+		}
+		return []string{val}, true
+	}
+}
+
+func (c *Context) headerSource() valueSource {
+	return func(key string) ([]string, bool) {
+		vals := c.Req.Header.Values(key)
+		return vals, len(vals) > 0
+	}
+}
+
+// FieldError 是某一个字段绑定失败的原因
+type FieldError struct {
+	// Field 是结构体里面的字段，嵌套的结构体用 . 连接，如 Address.City
+	Field string
+	// Key 是标签里面写的名字
+	Key string
+	// Source 是值的来源，query、form、path 或者 header
+	Source string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("web: 字段 %s 绑定失败, %s %q: %v", e.Field, e.Source, e.Key, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// BindingErrors 汇总了所有绑定失败的字段，不会遇到第一个错误就返回
+type BindingErrors []*FieldError
+
+func (e BindingErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// valueSource 根据 key 返回对应的值，第二个返回值表示有没有这个 key
+type valueSource func(key string) ([]string, bool)
+
+func checkBindTarget(val any) error {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errBindTarget
+	}
+	return nil
+}
+
+func bindStruct(val any, tag string, src valueSource) error {
+	if err := checkBindTarget(val); err != nil {
+		return err
+	}
+	var errs BindingErrors
+	rv := reflect.ValueOf(val).Elem()
+	b := binder{tag: tag, src: src, errs: &errs, visiting: map[reflect.Type]bool{rv.Type(): true}}
+	b.bindFields(rv, "")
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// binder 保存一次绑定的状态
+// visiting 是当前递归路径上的结构体类型，用来避免 type Node struct{ Next *Node } 这种自引用的类型无限递归
+type binder struct {
+	tag      string
+	src      valueSource
+	errs     *BindingErrors
+	visiting map[reflect.Type]bool
+}
+
+// bindFields 返回值表示是否至少设置了一个字段，用于决定要不要给 *struct 分配内存
+func (b binder) bindFields(rv reflect.Value, prefix string) bool {
+	tag, src, errs := b.tag, b.src, b.errs
+	rt := rv.Type()
+	set := false
+	for i := 0; i < rt.NumField(); i++ {
+		fd := rt.Field(i)
+		// 嵌入的结构体即使类型没有导出，它的导出字段也要绑定
+		if !fd.IsExported() && !fd.Anonymous {
+			continue
+		}
+		fv := rv.Field(i)
+		key := strings.Split(fd.Tag.Get(tag), ",")[0]
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			// 没有标签的结构体字段，当成嵌套结构体递归绑定
+			if b.bindNested(fv, fd, prefix) {
+				set = true
+			}
+			continue
+		}
+		// 没有导出的嵌入字段本身是不能设置的
+		if !fv.CanSet() {
+			continue
+		}
+		vals, ok := src(key)
+		if !ok || len(vals) == 0 {
+			continue
+		}
+		if err := setValue(fv, vals, fd.Tag.Get("time_format")); err != nil {
+			*errs = append(*errs, &FieldError{
+				Field:  prefix + fd.Name,
+				Key:    key,
+				Source: tag,
+				Err:    err,
+			})
+			continue
+		}
+		set = true
+	}
+	return set
+}
+
+func (b binder) bindNested(fv reflect.Value, fd reflect.StructField, prefix string) bool {
+	typ := fd.Type
+	isPtr := typ.Kind() == reflect.Pointer
+	if isPtr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ == timeType {
+		return false
+	}
+	// 嵌入的结构体不加前缀，和 json 的行为保持一致
+	nestedPrefix := prefix + fd.Name + "."
+	if fd.Anonymous {
+		nestedPrefix = prefix
+	}
+	if !isPtr {
+		return b.bindFields(fv, nestedPrefix)
+	}
+	// 自引用的类型不再往下递归
+	if b.visiting[typ] {
+		return false
+	}
+	// 指针只有在真的绑定上值的时候才分配，没有导出的嵌入指针分配不了
+	target := fv
+	if fv.IsNil() {
+		if !fv.CanSet() {
+			return false
+		}
+		target = reflect.New(typ)
+	}
+	b.visiting[typ] = true
+	defer delete(b.visiting, typ)
+	if !b.bindFields(target.Elem(), nestedPrefix) {
+		return false
+	}
+	if fv.IsNil() {
+		fv.Set(target)
+	}
+	return true
+}
+
+// setValue 把字符串转换成字段的类型
+// 切片会使用所有的值，其余类型只使用第一个值
+func setValue(fv reflect.Value, vals []string, layout string) error {
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setSingle(slice.Index(i), val, layout); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+	return setSingle(fv, vals[0], layout)
+}
+
+func setSingle(fv reflect.Value, val string, layout string) error {
+	if fv.Kind() == reflect.Pointer {
+		elem := reflect.New(fv.Type().Elem())
+		if err := setSingle(elem.Elem(), val, layout); err != nil {
+			return err
+		}
+		fv.Set(elem)
+		return nil
+	}
+
+	switch fv.Type() {
+	case timeType:
+		if val == "" {
+			return nil
+		}
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, val)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		if val == "" {
+			return nil
+		}
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	if fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(val))
+	}
+
+	if fv.Kind() == reflect.String {
+		fv.SetString(val)
+		return nil
+	}
+	// 空字符串对于其余类型来说就是零值，例如 ?page=
+	if val == "" {
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		// 只剩下 []byte
+		fv.SetBytes([]byte(val))
+	default:
+		return fmt.Errorf("web: 不支持的类型 %s", fv.Type())
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bindAddress struct {
+	City string `query:"city" form:"city"`
+	Zip  *int   `query:"zip"`
+}
+
+type bindPage struct {
+	Page int `query:"page"`
+	Size int `query:"size"`
+}
+
+type bindUser struct {
+	bindPage
+	Id       int64         `path:"id"`
+	Tenant   string        `header:"X-Tenant"`
+	Name     string        `form:"name"`
+	Admin    bool          `query:"admin"`
+	Score    float64       `query:"score"`
+	Uid      uint32        `query:"uid"`
+	Birthday time.Time     `query:"birthday" time_format:"2006-01-02"`
+	Timeout  time.Duration `query:"timeout"`
+	Tags     []string      `query:"tag"`
+	Ids      []int         `query:"ids"`
+	Nick     *string       `query:"nick"`
+	Address  bindAddress
+	Office   *bindAddress
+	Ignored  string `query:"-"`
+	internal string
+}
+
+func TestContext_BindQuery(t *testing.T) {
+	zip := 100000
+	nick := "tom"
+	testCases := []struct {
+		name    string
+		query   string
+		want    bindUser
+		wantErr string
+	}{
+		{
+			name:  "empty",
+			query: "",
+			want:  bindUser{},
+		},
+		{
+			name: "all types",
+			query: "page=2&size=20&admin=true&score=9.5&uid=12&birthday=2000-01-02&timeout=3s" +
+				"&tag=a&tag=b&ids=1&ids=2&nick=tom&city=beijing&zip=100000&Ignored=x",
+			want: bindUser{
+				bindPage: bindPage{Page: 2, Size: 20},
+				Admin:    true,
+				Score:    9.5,
+				Uid:      12,
+				Birthday: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC),
+				Timeout:  3 * time.Second,
+				Tags:     []string{"a", "b"},
+				Ids:      []int{1, 2},
+				Nick:     &nick,
+				Address:  bindAddress{City: "beijing", Zip: &zip},
+				Office:   &bindAddress{City: "beijing", Zip: &zip},
+			},
+		},
+		{
+			name:  "empty value",
+			query: "page=&admin=",
+			want:  bindUser{},
+		},
+		{
+			name:  "aggregate errors",
+			query: "page=abc&admin=yes&ids=1&ids=b&uid=-1",
+			want:  bindUser{},
+			wantErr: `web: 字段 Page 绑定失败, query "page": strconv.ParseInt: parsing "abc": invalid syntax; ` +
+				`web: 字段 Admin 绑定失败, query "admin": strconv.ParseBool: parsing "yes": invalid syntax; ` +
+				`web: 字段 Uid 绑定失败, query "uid": strconv.ParseUint: parsing "-1": invalid syntax; ` +
+				`web: 字段 Ids 绑定失败, query "ids": strconv.ParseInt: parsing "b": invalid syntax`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/user?"+tc.query, nil)
+			require.NoError(t, err)
+			ctx := &Context{Req: req}
+			var u bindUser
+			err = ctx.BindQuery(&u)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				var errs BindingErrors
+				assert.ErrorAs(t, err, &errs)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, u)
+		})
+	}
+}
+
+func TestContext_Bind(t *testing.T) {
+	form := url.Values{}
+	form.Set("name", "Tom")
+	req, err := http.NewRequest(http.MethodPost, "/user/12?page=3", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("x-tenant", "geektime")
+	ctx := &Context{Req: req, PathParams: map[string]string{"id": "12"}}
+
+	var u bindUser
+	require.NoError(t, ctx.Bind(&u))
+	assert.Equal(t, bindUser{
+		bindPage: bindPage{Page: 3},
+		Id:       12,
+		Tenant:   "geektime",
+		Name:     "Tom",
+	}, u)
+
+	// 多个来源的错误要合并
+	ctx.PathParams["id"] = "abc"
+	ctx.queryValues = nil
+	ctx.Req.URL.RawQuery = "page=abc"
+	err = ctx.Bind(&u)
+	var errs BindingErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "Id", errs[0].Field)
+	assert.Equal(t, "path", errs[0].Source)
+	assert.Equal(t, "Page", errs[1].Field)
+	assert.Equal(t, "query", errs[1].Source)
+
+	// 非法的目标
+	assert.Equal(t, errBindTarget, ctx.Bind(u))
+	assert.Equal(t, errBindTarget, ctx.Bind((*bindUser)(nil)))
+	var i int
+	assert.Equal(t, errBindTarget, ctx.BindQuery(&i))
+}
+
+type bindNode struct {
+	Name string `query:"name"`
+	Next *bindNode
+}
+
+type bindTree struct {
+	Root     *bindNode
+	Children []*bindNode `query:"-"`
+}
+
+type bindEmbedPtr struct {
+	*bindPage
+	*bindAddress
+}
+
+func TestContext_BindQuery_Nested(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "/user?name=tom&page=2&city=beijing", nil)
+	require.NoError(t, err)
+	ctx := &Context{Req: req}
+
+	// 自引用的类型不会无限递归
+	var node bindNode
+	require.NoError(t, ctx.BindQuery(&node))
+	assert.Equal(t, bindNode{Name: "tom"}, node)
+
+	var tree bindTree
+	require.NoError(t, ctx.BindQuery(&tree))
+	assert.Equal(t, bindTree{Root: &bindNode{Name: "tom"}}, tree)
+
+	// 没有导出的嵌入指针分配不了，直接跳过
+	var embed bindEmbedPtr
+	require.NoError(t, ctx.BindQuery(&embed))
+	assert.Nil(t, embed.bindPage)
+	assert.Nil(t, embed.bindAddress)
+
+	// 已经分配好的可以绑定
+	embed = bindEmbedPtr{bindPage: &bindPage{}}
+	require.NoError(t, ctx.BindQuery(&embed))
+	assert.Equal(t, &bindPage{Page: 2}, embed.bindPage)
+}
+
+func TestContext_Bind_Multipart(t *testing.T) {
+	req := newMultipartRequest(t, map[string]string{"name": "Tom", "city": "beijing"},
+		map[string][]byte{"avatar": pngHeader})
+	req.URL.RawQuery = "page=3&name=Jerry"
+	ctx := &Context{Req: req}
+
+	var u bindUser
+	require.NoError(t, ctx.Bind(&u))
+	// 请求体里面的值优先于查询参数
+	assert.Equal(t, "Tom", u.Name)
+	assert.Equal(t, 3, u.Page)
+
+	var addr struct {
+		City string   `form:"city"`
+		Name []string `form:"name"`
+	}
+	require.NoError(t, ctx.BindForm(&addr))
+	assert.Equal(t, "beijing", addr.City)
+	assert.Equal(t, []string{"Tom", "Jerry"}, addr.Name)
+
+	// 受 UploadLimits 的限制
+	req = newMultipartRequest(t, map[string]string{"name": "Tom"}, map[string][]byte{"avatar": pngHeader})
+	ctx = &Context{Req: req, uploadLimits: &UploadLimits{MaxTotalSize: 16}}
+	assert.ErrorIs(t, ctx.BindForm(&u), ErrUploadTooLarge)
+}
@@ -0,0 +1,349 @@
+package web
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	MIMEJSON          = "application/json"
+	MIMEXML           = "application/xml"
+	MIMETextXML       = "text/xml"
+	MIMEForm          = "application/x-www-form-urlencoded"
+	MIMEText          = "text/plain"
+	MIMEMultipartForm = "multipart/form-data"
+)
+
+var (
+	// ErrUnsupportedMediaType 请求的 Content-Type 没有对应的 Codec，Bind 会同时设置 415
+	ErrUnsupportedMediaType = errors.New("web: 不支持的 Content-Type")
+	// ErrNotAcceptable 没有 Codec 能满足请求的 Accept，Render 会同时设置 406
+	ErrNotAcceptable = errors.New("web: 没有满足 Accept 的响应格式")
+)
+
+// Codec 负责某一种媒体类型的编解码
+type Codec interface {
+	Decode(r io.Reader, val any) error
+	Encode(w io.Writer, val any) error
+}
+
+var (
+	codecsMutex sync.RWMutex
+	codecs      = map[string]Codec{
+		MIMEJSON:    jsonCodec{},
+		MIMEXML:     xmlCodec{},
+		MIMETextXML: xmlCodec{},
+		MIMEForm:    formCodec{},
+		MIMEText:    textCodec{},
+	}
+	// codecOrder 是注册的顺序，Accept 是 */* 或者没有 Accept 的时候用第一个
+	codecOrder = []string{MIMEJSON, MIMEXML, MIMEForm, MIMEText, MIMETextXML}
+)
+
+// RegisterCodec 注册或者覆盖某一种媒体类型的 Codec，例如 application/msgpack
+func RegisterCodec(mediaType string, codec Codec) {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "" || !strings.Contains(mediaType, "/") {
+		panic(fmt.Sprintf("web: 非法的媒体类型 [%s]", mediaType))
+	}
+	if codec == nil {
+		panic("web: Codec 不能为 nil")
+	}
+	codecsMutex.Lock()
+	defer codecsMutex.Unlock()
+	if _, ok := codecs[mediaType]; !ok {
+		codecOrder = append(codecOrder, mediaType)
+	}
+	codecs[mediaType] = codec
+}
+
+func lookupCodec(mediaType string) (Codec, bool) {
+	codecsMutex.RLock()
+	defer codecsMutex.RUnlock()
+	codec, ok := codecs[mediaType]
+	return codec, ok
+}
+
+// BindBody 按照 Content-Type 选择 Codec 解析请求体，然后校验
+// 没有对应的 Codec 时设置 415 并且返回 ErrUnsupportedMediaType
+func (c *Context) BindBody(val any) error {
+	if err := c.decodeBody(val); err != nil {
+		return err
+	}
+	return Validate(val)
+}
+
+func (c *Context) decodeBody(val any) error {
+	if c.Req.Body == nil || c.Req.Body == http.NoBody {
+		return nil
+	}
+	mediaType := MIMEJSON
+	if ct := c.Req.Header.Get("Content-Type"); ct != "" {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			c.unsupportedMediaType()
+			return ErrUnsupportedMediaType
+		}
+		mediaType = mt
+	}
+	codec, ok := lookupCodec(mediaType)
+	if !ok {
+		c.unsupportedMediaType()
+		return ErrUnsupportedMediaType
+	}
+	err := codec.Decode(c.Req.Body, val)
+	// 空的请求体不算错误，例如没有设置 Content-Length 的 GET 请求
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+func (c *Context) unsupportedMediaType() {
+	c.RespStatusCode = http.StatusUnsupportedMediaType
+	c.RespData = []byte(http.StatusText(http.StatusUnsupportedMediaType))
+}
+
+// Render 按照 Accept 选择 Codec 编码 val，并设置对应的 Content-Type
+// 没有 Codec 能满足 Accept 时设置 406 并且返回 ErrNotAcceptable
+func (c *Context) Render(status int, val any) error {
+	mediaType, codec, ok := negotiate(c.Req.Header.Get("Accept"))
+	if !ok {
+		c.RespStatusCode = http.StatusNotAcceptable
+		c.RespData = []byte(http.StatusText(http.StatusNotAcceptable))
+		return ErrNotAcceptable
+	}
+	var buf bytes.Buffer
+	if err := codec.Encode(&buf, val); err != nil {
+		return err
+	}
+	if strings.HasPrefix(mediaType, "text/") || mediaType == MIMEJSON || mediaType == MIMEXML {
+		mediaType += "; charset=utf-8"
+	}
+	c.Resp.Header().Set("Content-Type", mediaType)
+	c.RespStatusCode = status
+	c.RespData = buf.Bytes()
+	return nil
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// negotiate 按照 q 值从高到低匹配，支持 type/* 和 */*
+func negotiate(accept string) (string, Codec, bool) {
+	codecsMutex.RLock()
+	defer codecsMutex.RUnlock()
+	if strings.TrimSpace(accept) == "" {
+		return codecOrder[0], codecs[codecOrder[0]], true
+	}
+	ranges := make([]acceptRange, 0, 4)
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qs, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		ranges = append(ranges, acceptRange{mediaType: mt, q: q})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+	for _, r := range ranges {
+		if codec, ok := codecs[r.mediaType]; ok {
+			return r.mediaType, codec, true
+		}
+		if !strings.HasSuffix(r.mediaType, "*") {
+			continue
+		}
+		prefix := strings.TrimSuffix(r.mediaType, "*")
+		for _, mt := range codecOrder {
+			if strings.HasPrefix(mt, prefix) {
+				return mt, codecs[mt], true
+			}
+		}
+	}
+	return "", nil, false
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Decode(r io.Reader, val any) error {
+	return json.NewDecoder(r).Decode(val)
+}
+
+func (jsonCodec) Encode(w io.Writer, val any) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) Decode(r io.Reader, val any) error {
+	return xml.NewDecoder(r).Decode(val)
+}
+
+func (xmlCodec) Encode(w io.Writer, val any) error {
+	return xml.NewEncoder(w).Encode(val)
+}
+
+// formCodec 解码的时候使用 form 标签，和 BindForm 一致
+type formCodec struct{}
+
+func (formCodec) Decode(r io.Reader, val any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	return bindStruct(val, bindTagForm, func(key string) ([]string, bool) {
+		vals, ok := values[key]
+		return vals, ok
+	})
+}
+
+func (formCodec) Encode(w io.Writer, val any) error {
+	values, err := encodeForm(val)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, values.Encode())
+	return err
+}
+
+func encodeForm(val any) (url.Values, error) {
+	switch v := val.(type) {
+	case url.Values:
+		return v, nil
+	case map[string][]string:
+		return v, nil
+	case map[string]string:
+		values := make(url.Values, len(v))
+		for key, s := range v {
+			values.Set(key, s)
+		}
+		return values, nil
+	}
+	rv := reflect.ValueOf(val)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("web: 不支持编码成表单的类型 %T", val)
+	}
+	values := url.Values{}
+	encodeFormFields(rv, values)
+	return values, nil
+}
+
+func encodeFormFields(rv reflect.Value, values url.Values) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		fd := rt.Field(i)
+		if !fd.IsExported() && !fd.Anonymous {
+			continue
+		}
+		fv := rv.Field(i)
+		key := strings.Split(fd.Tag.Get(bindTagForm), ",")[0]
+		if key == "-" {
+			continue
+		}
+		for fv.Kind() == reflect.Pointer && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if key == "" {
+			if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+				encodeFormFields(fv, values)
+			}
+			continue
+		}
+		if fv.Kind() == reflect.Pointer {
+			continue
+		}
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+			for j := 0; j < fv.Len(); j++ {
+				values.Add(key, formatValue(fv.Index(j)))
+			}
+			continue
+		}
+		values.Set(key, formatValue(fv))
+	}
+}
+
+func formatValue(fv reflect.Value) string {
+	if fv.CanInterface() {
+		if tm, ok := fv.Interface().(encoding.TextMarshaler); ok {
+			data, err := tm.MarshalText()
+			if err == nil {
+				return string(data)
+			}
+		}
+	}
+	if fv.Kind() == reflect.Slice {
+		return string(fv.Bytes())
+	}
+	return fmt.Sprint(fv.Interface())
+}
+
+// textCodec 只能解码到 *string、*[]byte 或者 encoding.TextUnmarshaler
+type textCodec struct{}
+
+func (textCodec) Decode(r io.Reader, val any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	switch v := val.(type) {
+	case *string:
+		*v = string(data)
+	case *[]byte:
+		*v = data
+	case encoding.TextUnmarshaler:
+		return v.UnmarshalText(data)
+	default:
+		return fmt.Errorf("web: 不支持解码纯文本的类型 %T", val)
+	}
+	return nil
+}
+
+func (textCodec) Encode(w io.Writer, val any) error {
+	var err error
+	switch v := val.(type) {
+	case string:
+		_, err = io.WriteString(w, v)
+	case []byte:
+		_, err = w.Write(v)
+	default:
+		_, err = fmt.Fprint(w, v)
+	}
+	return err
+}
@@ -0,0 +1,147 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type codecUser struct {
+	Name string   `json:"name" xml:"name" form:"name" validate:"required"`
+	Tags []string `json:"tags" xml:"tag" form:"tag"`
+	Page int      `json:"-" xml:"-" query:"page"`
+}
+
+func TestContext_Bind_ContentType(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		want        codecUser
+		wantCode    int
+		wantErr     error
+	}{
+		{
+			name:        "json",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name":"Tom","tags":["a"]}`,
+			want:        codecUser{Name: "Tom", Tags: []string{"a"}, Page: 2},
+		},
+		{
+			name:        "xml",
+			contentType: "application/xml",
+			body:        `<codecUser><name>Tom</name><tag>a</tag><tag>b</tag></codecUser>`,
+			want:        codecUser{Name: "Tom", Tags: []string{"a", "b"}, Page: 2},
+		},
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        `name=Tom&tag=a`,
+			want:        codecUser{Name: "Tom", Tags: []string{"a"}, Page: 2},
+		},
+		{
+			name:        "unsupported",
+			contentType: "application/msgpack",
+			body:        `xxx`,
+			wantCode:    http.StatusUnsupportedMediaType,
+			wantErr:     ErrUnsupportedMediaType,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/user?page=2", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			ctx := &Context{Req: req}
+			var u codecUser
+			err := ctx.Bind(&u)
+			assert.Equal(t, tc.wantCode, ctx.RespStatusCode)
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, u)
+		})
+	}
+}
+
+func TestContext_Render(t *testing.T) {
+	testCases := []struct {
+		name            string
+		accept          string
+		val             any
+		wantCode        int
+		wantContentType string
+		wantData        string
+		wantErr         error
+	}{
+		{
+			name:            "no accept",
+			val:             codecUser{Name: "Tom"},
+			wantCode:        http.StatusOK,
+			wantContentType: "application/json; charset=utf-8",
+			wantData:        `{"name":"Tom","tags":null}`,
+		},
+		{
+			name:            "xml by q",
+			accept:          "application/json;q=0.5, application/xml",
+			val:             codecUser{Name: "Tom", Tags: []string{"a"}},
+			wantCode:        http.StatusOK,
+			wantContentType: "application/xml; charset=utf-8",
+			wantData:        `<codecUser><name>Tom</name><tag>a</tag></codecUser>`,
+		},
+		{
+			name:            "text wildcard",
+			accept:          "text/*",
+			val:             "hello",
+			wantCode:        http.StatusOK,
+			wantContentType: "text/plain; charset=utf-8",
+			wantData:        `hello`,
+		},
+		{
+			name:            "text xml",
+			accept:          "text/xml, */*;q=0.1",
+			val:             "hello",
+			wantCode:        http.StatusOK,
+			wantContentType: "text/xml; charset=utf-8",
+			wantData:        `<string>hello</string>`,
+		},
+		{
+			name:            "form",
+			accept:          "application/x-www-form-urlencoded",
+			val:             &codecUser{Name: "Tom", Tags: []string{"a", "b"}},
+			wantCode:        http.StatusOK,
+			wantContentType: "application/x-www-form-urlencoded",
+			wantData:        `name=Tom&tag=a&tag=b`,
+		},
+		{
+			name:     "not acceptable",
+			accept:   "image/png, application/json;q=0",
+			val:      codecUser{Name: "Tom"},
+			wantCode: http.StatusNotAcceptable,
+			wantData: "Not Acceptable",
+			wantErr:  ErrNotAcceptable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/user", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			resp := httptest.NewRecorder()
+			ctx := &Context{Req: req, Resp: resp}
+			err := ctx.Render(http.StatusOK, tc.val)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantCode, ctx.RespStatusCode)
+			assert.Equal(t, tc.wantContentType, resp.Header().Get("Content-Type"))
+			assert.Equal(t, tc.wantData, string(ctx.RespData))
+		})
+	}
+}
@@ -1,30 +1,173 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/url"
-	"strconv"
+	"sync"
+	"time"
 )
 
+// 确保 *Context 实现了 context.Context，可以直接传给数据库、RPC、tracer 等调用
+var _ context.Context = &Context{}
+
+// Context 每个请求创建一个，不会复用，handler 返回之后派生出来的 context 仍然可以使用
+// 但是 Resp 在请求结束之后就不能再写了，需要在 goroutine 里面回写响应的话用 Clone
 type Context struct {
 	Req *http.Request
 
-	//如果用户用这个，就绕开了RespStatusCode、RespData， 就获取不到，
-	//那么部分middleware就无法运作
+	//如果用户用这个，就绕开了RespStatusCode、RespData
+	//HttpServer 会包装一层，记录直接写进去的状态码和字节数，执行完 handler 之后同步到 RespStatusCode
+	//middleware 可以通过 RespWritten、RespSize 判断
 	Resp http.ResponseWriter
 
 	//为了在middleware里读写用的
 	//缓存响应部分，这部分在最后刷新
 	RespStatusCode int
 	RespData       []byte
+	// RespErr 是 Stream、RespReader 这种直接写 Resp 的过程中发生的错误，例如客户端断开
+	RespErr error
+	// TimedOut 表示请求处理超时了，由 middlewares/timeout 设置，accesslog、prometheus 会记录下来
+	TimedOut bool
 
 	PathParams map[string]string
 	//缓存住query数据，避免重复解析
 	queryValues url.Values
 	//命中的路由
 	MatchedRoute string
+
+	// Context 自己的值，Value 查找时优先于 Req.Context()
+	// ctx 会被传给别的 goroutine，所以读写都要加锁
+	valuesMutex sync.RWMutex
+	values      map[any]any
+
+	// resp 是 HttpServer 包装之后的 Resp，respFlushed 表示 RespData 已经回写
+	// rw 和 Context 一起分配，resp 指向它
+	resp        *responseWriter
+	rw          responseWriter
+	respFlushed bool
+
+	// 上传的限制来自 HttpServer，解析好的表单在请求结束之后清理
+	uploadLimits  *UploadLimits
+	multipartForm *MultipartForm
+
+	// 模板引擎来自 HttpServer，RenderTemplate 使用
+	tplEngine TemplateEngine
+
+	// 缓存 mTLS 客户端证书解析出来的身份
+	identity *ClientIdentity
+
+	// 日志来自 HttpServer，logFields 是 AddLogFields 添加的字段，见 logger.go
+	logger    Logger
+	logFields []any
+	requestID string
+}
+
+// Deadline、Done、Err 都委托给 Req.Context()
+// 每次都重新读取 c.Req，所以 middleware 替换了 Req（比如 opentelemetry）之后也能拿到最新的
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	return c.reqContext().Deadline()
+}
+
+func (c *Context) Done() <-chan struct{} {
+	return c.reqContext().Done()
+}
+
+func (c *Context) Err() error {
+	return c.reqContext().Err()
+}
+
+// Value 先查 SetValue 设置的值，找不到再去 Req.Context() 里面找
+func (c *Context) Value(key any) any {
+	c.valuesMutex.RLock()
+	val, ok := c.values[key]
+	c.valuesMutex.RUnlock()
+	if ok {
+		return val
+	}
+	return c.reqContext().Value(key)
+}
+
+// SetValue 在 Context 上保存一个值，后续可以通过 Value 读取
+// 和 context.WithValue 一样，key 最好使用自定义类型，避免冲突
+func (c *Context) SetValue(key any, val any) {
+	c.valuesMutex.Lock()
+	defer c.valuesMutex.Unlock()
+	if c.values == nil {
+		c.values = make(map[any]any, 4)
+	}
+	c.values[key] = val
+}
+
+// reset 把 Context 初始化成处理一个新请求的状态
+func (c *Context) reset(w http.ResponseWriter, r *http.Request) {
+	*c = Context{Req: r}
+	c.rw.ResponseWriter = w
+	c.Resp = &c.rw
+	c.resp = &c.rw
+}
+
+// Clone 复制一个 Context，Resp 换成 w，用于在另外的 goroutine 里面执行 handler，例如超时控制
+// 返回的 Context 和原来的互相独立，SetValue 不会影响原来的 Context
+// 它解析出来的上传文件需要自己调用 RemoveUploadedFiles 清理
+func (c *Context) Clone(w http.ResponseWriter) *Context {
+	res := &Context{
+		Req:            c.Req,
+		RespStatusCode: c.RespStatusCode,
+		RespData:       c.RespData,
+		RespErr:        c.RespErr,
+		TimedOut:       c.TimedOut,
+		PathParams:     c.PathParams,
+		queryValues:    c.queryValues,
+		MatchedRoute:   c.MatchedRoute,
+		uploadLimits:   c.uploadLimits,
+		tplEngine:      c.tplEngine,
+		identity:       c.identity,
+		logger:         c.logger,
+		requestID:      c.requestID,
+	}
+	if len(c.logFields) > 0 {
+		res.logFields = append([]any(nil), c.logFields...)
+	}
+	c.valuesMutex.RLock()
+	if len(c.values) > 0 {
+		res.values = make(map[any]any, len(c.values))
+		for k, v := range c.values {
+			res.values[k] = v
+		}
+	}
+	c.valuesMutex.RUnlock()
+	res.rw.ResponseWriter = w
+	res.Resp = &res.rw
+	res.resp = &res.rw
+	return res
+}
+
+func (c *Context) reqContext() context.Context {
+	if c.Req == nil {
+		return context.Background()
+	}
+	return c.Req.Context()
+}
+
+// RespWritten 表示状态码已经直接写到了 Resp 里面
+// 这个时候再修改 RespStatusCode、RespData 已经改变不了响应了
+func (c *Context) RespWritten() bool {
+	return c.resp != nil && (c.resp.wroteHeader || c.resp.hijacked)
+}
+
+// RespSize 是响应体的字节数，包括直接写到 Resp 的部分和还没有回写的 RespData
+func (c *Context) RespSize() int {
+	size := 0
+	if c.resp != nil {
+		size = c.resp.size
+	}
+	if !c.respFlushed {
+		size += len(c.RespData)
+	}
+	return size
 }
 
 func (c *Context) RespJsonOK(val any) error {
@@ -58,7 +201,11 @@ func (c *Context) BindJson(val any) error {
 	//decoder.UseNumber()
 	//json中如果有未知字段就会报错
 	//decoder.DisallowUnknownFields()
-	return decoder.Decode(val)
+	if err := decoder.Decode(val); err != nil {
+		return err
+	}
+	// 解析成功之后按照 validate 标签校验
+	return Validate(val)
 }
 
 // From是有缓存的，比如调用ParseForm，里面会判断if r.PostForm == nil， 所以不用担心重复调用重复解析的问题
@@ -74,51 +221,25 @@ func (c *Context) FormValue(key string) (string, error) {
 func (c *Context) QueryValue(key string) (string, error) {
 	//这种调用区别不出来是真的有值，值为空字符串，还是没有值
 	//return c.Req.Form.Get(key), nil
-	if c.queryValues == nil {
-		c.queryValues = c.Req.URL.Query()
-	}
-	vals, ok := c.queryValues[key]
+	vals, ok := c.query()[key]
 	if !ok {
-		return "", errors.New("web: key 不存在")
+		return "", &KeyNotFoundError{Source: "query", Key: key}
 	}
 	return vals[0], nil
 
 }
 
-func (c *Context) PathValue(key string) (string, error) {
-	val, ok := c.PathParams[key]
-	if !ok {
-		return "", errors.New("web: key 不存在")
+func (c *Context) query() url.Values {
+	if c.queryValues == nil {
+		c.queryValues = c.Req.URL.Query()
 	}
-	return val, nil
+	return c.queryValues
 }
 
-func (c *Context) PathValue1(key string) StringValue {
+func (c *Context) PathValue(key string) (string, error) {
 	val, ok := c.PathParams[key]
 	if !ok {
-		return StringValue{
-			err: errors.New("web: key 不存在"),
-		}
-	}
-	return StringValue{
-		val: val,
-	}
-}
-
-type StringValue struct {
-	val string
-	err error
-}
-
-func (s StringValue) AsInt64() (int64, error) {
-	if s.err != nil {
-		return 0, s.err
+		return "", &KeyNotFoundError{Source: "path", Key: key}
 	}
-	return strconv.ParseInt(s.val, 10, 64)
-
+	return val, nil
 }
-
-// 不能用泛型
-// func (s StringValue) To[T any]() (T, error) {
-//
-// }
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ctxKey string
+
+func TestContext_Context(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "/user", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqCtx := context.WithValue(req.Context(), ctxKey("req"), "req value")
+	reqCtx, cancel := context.WithTimeout(reqCtx, time.Minute)
+	defer cancel()
+	ctx := &Context{Req: req.WithContext(reqCtx)}
+
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	wantDeadline, _ := reqCtx.Deadline()
+	assert.Equal(t, wantDeadline, deadline)
+
+	// 自己的值优先
+	assert.Equal(t, "req value", ctx.Value(ctxKey("req")))
+	ctx.SetValue(ctxKey("req"), "ctx value")
+	assert.Equal(t, "ctx value", ctx.Value(ctxKey("req")))
+	assert.Nil(t, ctx.Value(ctxKey("not exist")))
+
+	// middleware 替换了 Req 之后，要能拿到新的 context
+	newReqCtx, newCancel := context.WithCancel(context.Background())
+	ctx.Req = ctx.Req.WithContext(newReqCtx)
+	_, ok = ctx.Deadline()
+	assert.False(t, ok)
+	assert.Nil(t, ctx.Err())
+	newCancel()
+	<-ctx.Done()
+	assert.Equal(t, context.Canceled, ctx.Err())
+
+	// 可以直接作为 context.Context 的父 context
+	child, childCancel := context.WithCancel(ctx)
+	defer childCancel()
+	assert.Equal(t, "ctx value", child.Value(ctxKey("req")))
+	<-child.Done()
+}
+
+func TestContext_Value_Concurrent(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "/", nil)
+	assert.NoError(t, err)
+	ctx := &Context{Req: req}
+	done := make(chan struct{})
+	// 把 ctx 交给别的 goroutine 之后，handler 还在继续 SetValue
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = ctx.Value(ctxKey("user"))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		ctx.SetValue(ctxKey("user"), i)
+	}
+	<-done
+	assert.Equal(t, 99, ctx.Value(ctxKey("user")))
+}
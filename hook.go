@@ -0,0 +1,118 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Hook 是生命周期的回调，ctx 带着注册时指定的超时时间
+type Hook func(ctx context.Context) error
+
+// AfterStartHook 多了实际监听的地址，监听 :0 的时候可以拿到系统分配的端口
+type AfterStartHook func(ctx context.Context, addr net.Addr) error
+
+type hookEntry struct {
+	timeout time.Duration
+	hook    Hook
+}
+
+// OnBeforeStart 在开始接收请求之前执行，例如预热缓存
+// 任何一个返回 error 服务器都不会启动，Start 返回这些 error
+// 所有的 OnXXX 都按照注册的顺序执行，timeout 为 0 表示不限制时间，需要在 Start 之前注册
+func (h *HttpServer) OnBeforeStart(timeout time.Duration, hook Hook) {
+	h.beforeStart = append(h.beforeStart, hookEntry{timeout: timeout, hook: hook})
+}
+
+// OnAfterStart 在开始监听之后执行，例如注册到服务发现
+// 任何一个返回 error 都会关闭服务器，Start 返回这些 error
+func (h *HttpServer) OnAfterStart(timeout time.Duration, hook AfterStartHook) {
+	h.afterStart = append(h.afterStart, func(addr net.Addr) hookEntry {
+		return hookEntry{timeout: timeout, hook: func(ctx context.Context) error {
+			return hook(ctx, addr)
+		}}
+	})
+}
+
+// OnBeforeShutdown 在 Shutdown 开始的时候执行，例如从服务发现里面摘掉自己
+// 返回 error 也会继续退出，这些 error 会合并到 Shutdown 的返回值里面
+func (h *HttpServer) OnBeforeShutdown(timeout time.Duration, hook Hook) {
+	h.beforeShutdown = append(h.beforeShutdown, hookEntry{timeout: timeout, hook: hook})
+}
+
+// OnAfterShutdown 在所有的请求都结束之后执行，例如把缓冲的日志、指标刷出去
+// Shutdown 超时了也会执行
+func (h *HttpServer) OnAfterShutdown(timeout time.Duration, hook Hook) {
+	h.afterShutdown = append(h.afterShutdown, hookEntry{timeout: timeout, hook: hook})
+}
+
+// runHooks 依次执行 hooks，某一个失败了也会继续执行后面的，返回所有的 error
+func runHooks(ctx context.Context, stage string, hooks []hookEntry) error {
+	var errs MultiError
+	for i, entry := range hooks {
+		if err := runHook(ctx, entry); err != nil {
+			errs = append(errs, fmt.Errorf("web: %s 第 %d 个 hook 失败: %w", stage, i+1, err))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+func runHook(ctx context.Context, entry hookEntry) error {
+	if entry.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, entry.timeout)
+		defer cancel()
+	}
+	// hook 不理会 ctx 的话，到了时间也不再等它
+	done := make(chan error, 1)
+	go func() {
+		done <- entry.hook(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// MultiError 是多个 error 合并之后的结果
+type MultiError []error
+
+func (m MultiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap 让 errors.Is、errors.As 可以找到里面的 error
+func (m MultiError) Unwrap() []error {
+	return m
+}
+
+// ErrorOrNil 没有 error 的时候返回 nil，只有一个的时候直接返回它
+func (m MultiError) ErrorOrNil() error {
+	switch len(m) {
+	case 0:
+		return nil
+	case 1:
+		return m[0]
+	default:
+		return m
+	}
+}
+
+// appendErr 合并 error，忽略 nil
+func appendErr(errs MultiError, err error) MultiError {
+	if err == nil {
+		return errs
+	}
+	if me, ok := err.(MultiError); ok {
+		return append(errs, me...)
+	}
+	return append(errs, err)
+}
@@ -0,0 +1,118 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpServer_Hooks(t *testing.T) {
+	// 超时的 hook 会在别的 goroutine 里面继续运行，所以要加锁
+	var mutex sync.Mutex
+	var calls []string
+	record := func(call string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls = append(calls, call)
+	}
+	server := NewHTTPServer()
+	server.OnBeforeStart(time.Second, func(ctx context.Context) error {
+		record("before start 1")
+		return nil
+	})
+	server.OnBeforeStart(time.Second, func(ctx context.Context) error {
+		record("before start 2")
+		return nil
+	})
+	addrCh := make(chan net.Addr, 1)
+	server.OnAfterStart(time.Second, func(ctx context.Context, addr net.Addr) error {
+		record("after start")
+		addrCh <- addr
+		return nil
+	})
+	flushErr := errors.New("flush failed")
+	server.OnBeforeShutdown(time.Second, func(ctx context.Context) error {
+		record("before shutdown")
+		return nil
+	})
+	server.OnAfterShutdown(time.Second, func(ctx context.Context) error {
+		record("after shutdown")
+		return flushErr
+	})
+	server.OnAfterShutdown(10*time.Millisecond, func(ctx context.Context) error {
+		record("after shutdown timeout")
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() {
+		served <- server.serve(ln)
+	}()
+	// :0 的时候可以拿到实际的端口
+	assert.Equal(t, ln.Addr(), <-addrCh)
+
+	err = server.Shutdown(context.Background())
+	assert.Equal(t, http.ErrServerClosed, <-served)
+	// 所有的 error 都会返回
+	assert.ErrorIs(t, err, flushErr)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, "web: OnAfterShutdown 第 1 个 hook 失败: flush failed; "+
+		"web: OnAfterShutdown 第 2 个 hook 失败: context deadline exceeded", err.Error())
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, []string{"before start 1", "before start 2", "after start",
+		"before shutdown", "after shutdown", "after shutdown timeout"}, calls)
+}
+
+func TestHttpServer_Hooks_StartFailed(t *testing.T) {
+	testCases := []struct {
+		name    string
+		setup   func(server *HttpServer)
+		wantErr string
+	}{
+		{
+			name: "before start",
+			setup: func(server *HttpServer) {
+				server.OnBeforeStart(time.Second, func(ctx context.Context) error {
+					return errors.New("warm up failed")
+				})
+				server.OnAfterStart(time.Second, func(ctx context.Context, addr net.Addr) error {
+					t.Fatal("不应该执行")
+					return nil
+				})
+			},
+			wantErr: "web: OnBeforeStart 第 1 个 hook 失败: warm up failed",
+		},
+		{
+			name: "after start",
+			setup: func(server *HttpServer) {
+				server.OnAfterStart(time.Second, func(ctx context.Context, addr net.Addr) error {
+					return errors.New("register failed")
+				})
+			},
+			wantErr: "web: OnAfterStart 第 1 个 hook 失败: register failed",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewHTTPServer()
+			tc.setup(server)
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			assert.EqualError(t, server.serve(ln), tc.wantErr)
+			// 端口已经释放了
+			_, err = net.Dial("tcp", ln.Addr().String())
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+)
+
+// ServerWithClientAuth 开启 mTLS，用 caPool 校验客户端的证书
+// auth 一般是 tls.RequireAndVerifyClientCert，或者 tls.VerifyClientCertIfGiven 允许不带证书的客户端
+func ServerWithClientAuth(auth tls.ClientAuthType, caPool *x509.CertPool) HTTPServerOption {
+	return func(server *HttpServer) {
+		server.clientAuth = auth
+		server.clientCAs = caPool
+	}
+}
+
+// LoadCertPool 从 PEM 文件加载 CA 证书
+func LoadCertPool(files ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("web: " + file + " 里面没有有效的证书")
+		}
+	}
+	return pool, nil
+}
+
+// ClientIdentity 是 mTLS 里面客户端证书对应的身份
+type ClientIdentity struct {
+	Subject        pkix.Name
+	DNSNames       []string
+	EmailAddresses []string
+	IPAddresses    []net.IP
+	URIs           []*url.URL
+	// SPIFFEID 是 URI SAN 里面以 spiffe:// 开头的那一个，没有就是空字符串
+	SPIFFEID string
+
+	Certificate *x509.Certificate
+}
+
+// ClientIdentity 返回客户端证书对应的身份
+// 只有校验通过的证书才算数，没有开启 mTLS、客户端没有带证书的时候返回 false
+func (c *Context) ClientIdentity() (*ClientIdentity, bool) {
+	if c.identity != nil {
+		return c.identity, true
+	}
+	if c.Req == nil || c.Req.TLS == nil ||
+		len(c.Req.TLS.VerifiedChains) == 0 || len(c.Req.TLS.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	cert := c.Req.TLS.VerifiedChains[0][0]
+	id := &ClientIdentity{
+		Subject:        cert.Subject,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		IPAddresses:    cert.IPAddresses,
+		URIs:           cert.URIs,
+		Certificate:    cert,
+	}
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			id.SPIFFEID = uri.String()
+			break
+		}
+	}
+	c.identity = id
+	return id, true
+}
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpServer_ClientAuth(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	spiffe, err := url.Parse("spiffe://example.org/ns/payments/sa/api")
+	require.NoError(t, err)
+	clientCert := issueTestCert(t, ca, caKey, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "api", OrganizationalUnit: []string{"payments"}},
+		DNSNames:    []string{"api.payments.svc"},
+		URIs:        []*url.URL{spiffe},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+
+	server := NewHTTPServer(ServerWithSelfSignedCert(),
+		ServerWithClientAuth(tls.VerifyClientCertIfGiven, pool))
+	server.Get("/whoami", func(ctx *Context) {
+		id, ok := ctx.ClientIdentity()
+		if !ok {
+			ctx.RespStatusCode = http.StatusUnauthorized
+			return
+		}
+		ctx.RespData = []byte(id.Subject.CommonName + " " + id.SPIFFEID + " " + id.DNSNames[0])
+	})
+	cfg, err := server.buildTLSConfig("", "")
+	require.NoError(t, err)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = server.serve(tls.NewListener(ln, cfg))
+	}()
+	defer server.srv.Close()
+
+	testCases := []struct {
+		name     string
+		certs    []tls.Certificate
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "with cert",
+			certs:    []tls.Certificate{clientCert},
+			wantCode: http.StatusOK,
+			wantBody: "api spiffe://example.org/ns/payments/sa/api api.payments.svc",
+		},
+		{
+			name:     "without cert",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+				Certificates:       tc.certs,
+			}}}
+			resp, err := client.Get("https://" + ln.Addr().String() + "/whoami")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, tc.wantCode, resp.StatusCode)
+			body := make([]byte, 128)
+			n, _ := resp.Body.Read(body)
+			assert.Equal(t, tc.wantBody, string(body[:n]))
+		})
+	}
+
+	// 不是这个 CA 签发的证书握手失败
+	otherCA, otherKey := newTestCA(t)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		InsecureSkipVerify: true,
+		Certificates: []tls.Certificate{issueTestCert(t, otherCA, otherKey, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "evil"},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})},
+	}}}
+	_, err = client.Get("https://" + ln.Addr().String() + "/whoami")
+	assert.Error(t, err)
+}
+
+func newTestCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return ca, key
+}
+
+func issueTestCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, tpl *x509.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tpl.NotBefore = time.Now().Add(-time.Hour)
+	tpl.NotAfter = time.Now().Add(time.Hour)
+	tpl.KeyUsage = x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
@@ -0,0 +1,239 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	unixScheme    = "unix://"
+	systemdScheme = "systemd://"
+)
+
+// ServerWithSocketMode 设置 unix socket 文件的权限，例如 0660 只允许同一个组的进程连接
+func ServerWithSocketMode(mode fs.FileMode) HTTPServerOption {
+	return func(server *HttpServer) {
+		server.socketMode = mode
+	}
+}
+
+// DefaultListener 是 Start、StartTLS、Serve 的 listener 的名字
+const DefaultListener = "default"
+
+// ServerWithListener 除了 Start 的地址以外，再监听 addr，可以调用多次
+// 例如对外的 :8080 和对内的 :9090，配合 middlewares/listener 可以让 /metrics 之类的路由只在对内的端口上访问
+// addr 的格式和 Start 一样，Shutdown 会关闭所有的 listener
+func ServerWithListener(name string, addr string) HTTPServerOption {
+	return func(server *HttpServer) {
+		server.extraListeners = append(server.extraListeners, listenerEntry{name: name, addr: addr})
+	}
+}
+
+// Serve 在已经创建好的 listener 上面处理请求，和 Start 一样会一直阻塞
+// ServerWithListener 添加的 listener 在这里不生效
+func (h *HttpServer) Serve(ln net.Listener) error {
+	return h.serve(&namedListener{Listener: ln, name: DefaultListener})
+}
+
+// ListenerName 是接收到这个请求的 listener 的名字
+func (c *Context) ListenerName() string {
+	name, _ := c.reqContext().Value(listenerKey{}).(string)
+	return name
+}
+
+type listenerEntry struct {
+	name string
+	addr string
+}
+
+// listenAll 监听 addr 以及 ServerWithListener 添加的地址，wrap 用来包装 TLS
+func (h *HttpServer) listenAll(addr string, wrap func(ln net.Listener) net.Listener) ([]net.Listener, error) {
+	entries := append([]listenerEntry{{name: DefaultListener, addr: addr}}, h.extraListeners...)
+	lns := make([]net.Listener, 0, len(entries))
+	for _, entry := range entries {
+		raw, err := h.listen(entry.addr)
+		if err != nil {
+			for _, ln := range lns {
+				_ = ln.Close()
+			}
+			return nil, err
+		}
+		// 名字要包在 TLS 里面，不然 http.Server 识别不出 *tls.Conn
+		var ln net.Listener = &namedListener{Listener: raw, name: entry.name}
+		if wrap != nil {
+			ln = wrap(ln)
+		}
+		lns = append(lns, ln)
+	}
+	return lns, nil
+}
+
+type listenerKey struct{}
+
+// listenerConnContext 把 listener 的名字放到连接的 context 里面，这个连接上的请求都可以拿到
+func listenerConnContext(ctx context.Context, conn net.Conn) context.Context {
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
+	if nc, ok := conn.(*namedConn); ok {
+		return context.WithValue(ctx, listenerKey{}, nc.name)
+	}
+	return ctx
+}
+
+// namedListener 给接收到的连接打上 listener 的名字
+type namedListener struct {
+	net.Listener
+	name string
+}
+
+func (l *namedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &namedConn{Conn: conn, name: l.name}, nil
+}
+
+type namedConn struct {
+	net.Conn
+	name string
+}
+
+// ReadFrom 保留底层连接的 io.ReaderFrom，这样静态文件依旧可以用上 sendfile
+func (c *namedConn) ReadFrom(r io.Reader) (int64, error) {
+	if rf, ok := c.Conn.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(c.Conn, r)
+}
+
+// listen 支持三种地址
+// 1. 普通的 TCP 地址，例如 :8080
+// 2. unix:///run/app.sock，已经存在的 socket 文件会被删除
+// 3. systemd:// 或者 systemd://name，使用 systemd socket activation 传进来的 listener，name 对应 FileDescriptorName
+// 平滑升级之后的子进程优先使用父进程传下来的 listener，见 Upgrade
+func (h *HttpServer) listen(addr string) (net.Listener, error) {
+	ln, ok := inheritedListener(addr)
+	if !ok {
+		var err error
+		switch {
+		case strings.HasPrefix(addr, unixScheme):
+			ln, err = h.listenUnix(strings.TrimPrefix(addr, unixScheme))
+		case strings.HasPrefix(addr, systemdScheme):
+			ln, err = systemdListener(strings.TrimPrefix(addr, systemdScheme))
+		default:
+			lc := net.ListenConfig{KeepAlive: h.tcpKeepAlive}
+			ln, err = lc.Listen(context.Background(), "tcp", addr)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	h.lnMutex.Lock()
+	defer h.lnMutex.Unlock()
+	if h.listeners == nil {
+		h.listeners = make(map[string]net.Listener)
+	}
+	h.listeners[addr] = ln
+	return ln, nil
+}
+
+func (h *HttpServer) listenUnix(path string) (net.Listener, error) {
+	// 上一次没有正常退出留下来的 socket 文件，不删掉的话会 address already in use
+	if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSocket != 0 {
+		if err = os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if h.socketMode != 0 {
+		if err = os.Chmod(path, h.socketMode); err != nil {
+			_ = ln.Close()
+			return nil, err
+		}
+	}
+	return ln, nil
+}
+
+var (
+	systemdOnce  sync.Once
+	systemdLns   []net.Listener
+	systemdNames []string
+	systemdErr   error
+)
+
+// SystemdListeners 返回 systemd socket activation 传进来的 listener，按照 fd 的顺序排列
+// 只会解析一次，并且会清掉 LISTEN_PID 等环境变量，避免子进程误用
+func SystemdListeners() ([]net.Listener, error) {
+	systemdOnce.Do(func() {
+		systemdLns, systemdNames, systemdErr = loadSystemdListeners(os.Getenv, 3)
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	})
+	return systemdLns, systemdErr
+}
+
+func systemdListener(name string) (net.Listener, error) {
+	lns, err := SystemdListeners()
+	if err != nil {
+		return nil, err
+	}
+	for i, ln := range lns {
+		if name == "" || systemdNames[i] == name {
+			return ln, nil
+		}
+	}
+	return nil, fmt.Errorf("web: 没有 systemd 传递的 listener [%s]", name)
+}
+
+// loadSystemdListeners 按照 sd_listen_fds 的约定解析，fd 从 start 开始连续排列
+func loadSystemdListeners(getenv func(string) string, start int) ([]net.Listener, []string, error) {
+	if getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil, nil
+	}
+	n, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil, errors.New("web: LISTEN_FDS 不合法")
+	}
+	names := strings.Split(getenv("LISTEN_FDNAMES"), ":")
+	resNames := make([]string, n)
+	copy(resNames, names)
+	lns, err := fdListeners(start, n)
+	if err != nil {
+		return nil, nil, err
+	}
+	return lns, resNames, nil
+}
+
+// fdListeners 把从 start 开始的 n 个 fd 转换成 listener
+func fdListeners(start int, n int) ([]net.Listener, error) {
+	lns := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		f := os.NewFile(uintptr(start+i), "listener-"+strconv.Itoa(i))
+		// FileListener 会 dup 一个新的 fd，原来的就可以关掉了
+		ln, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			for _, l := range lns {
+				_ = l.Close()
+			}
+			return nil, err
+		}
+		lns = append(lns, ln)
+	}
+	return lns, nil
+}
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpServer_StartUnix(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	// 上一次留下来的 socket 文件
+	old, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	old.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, old.Close())
+
+	server := NewHTTPServer(ServerWithSocketMode(0o660))
+	server.Get("/hello", func(ctx *Context) {
+		ctx.RespData = []byte("hello")
+	})
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Start("unix://" + sock)
+	}()
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return false
+		}
+		_ = conn.Close()
+		return true
+	}, time.Second, 10*time.Millisecond)
+
+	info, err := os.Stat(sock)
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o660), info.Mode().Perm())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	resp, err := client.Get("http://unix/hello")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, "hello", string(body))
+
+	require.NoError(t, server.Shutdown(context.Background()))
+	assert.Equal(t, http.ErrServerClosed, <-served)
+	// 关闭之后 socket 文件被删除
+	_, err = os.Stat(sock)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLoadSystemdListeners(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer tcp.Close()
+	f, err := tcp.(*net.TCPListener).File()
+	require.NoError(t, err)
+	defer f.Close()
+
+	testCases := []struct {
+		name      string
+		env       map[string]string
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			name: "other process",
+			env:  map[string]string{"LISTEN_PID": "1", "LISTEN_FDS": "1"},
+		},
+		{
+			name: "named",
+			env: map[string]string{"LISTEN_PID": strconv.Itoa(os.Getpid()), "LISTEN_FDS": "1",
+				"LISTEN_FDNAMES": "http"},
+			wantNames: []string{"http"},
+		},
+		{
+			name:    "invalid fds",
+			env:     map[string]string{"LISTEN_PID": strconv.Itoa(os.Getpid()), "LISTEN_FDS": "abc"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// loadSystemdListeners 会关掉传进去的 fd，所以每次都 dup 一个
+			fd, err := dupFile(f)
+			require.NoError(t, err)
+			defer fd.Close()
+			lns, names, err := loadSystemdListeners(func(key string) string {
+				return tc.env[key]
+			}, int(fd.Fd()))
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantNames, names)
+			for _, ln := range lns {
+				assert.Equal(t, tcp.Addr().String(), ln.Addr().String())
+				_ = ln.Close()
+			}
+		})
+	}
+}
+
+// dupFile 借助 net.FileListener 复制一个 fd
+func dupFile(f *os.File) (*os.File, error) {
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, err
+	}
+	defer ln.Close()
+	return ln.(*net.TCPListener).File()
+}
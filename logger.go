@@ -0,0 +1,183 @@
+package web
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Level 是日志的级别，取值和 log/slog 一致
+type Level int
+
+const (
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelWarn  Level = 4
+	LevelError Level = 8
+)
+
+func (l Level) String() string {
+	switch {
+	case l < LevelInfo:
+		return "DEBUG"
+	case l < LevelWarn:
+		return "INFO"
+	case l < LevelError:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+// Logger 是结构化的日志接口，args 是 key、value 交替出现的字段，和 log/slog 的用法一样
+// 例如 logger.Info("服务器启动", "addr", ":8080")
+// Go 1.21 以上可以用 NewSlogLogger 接入 slog
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+	// With 返回一个新的 Logger，之后的每条日志都带上 args
+	With(args ...any) Logger
+}
+
+// ServerWithLogger 设置 HttpServer 的日志，默认是输出到标准错误的 JSON 日志，级别是 Info
+func ServerWithLogger(logger Logger) HTTPServerOption {
+	return func(server *HttpServer) {
+		server.logger = logger
+	}
+}
+
+var defaultLogger = NewJSONLogger(os.Stderr, LevelInfo)
+
+// NewJSONLogger 每条日志输出一行 JSON，低于 level 的日志会被丢弃
+// 固定的字段是 time、level、msg，后面按照顺序输出 With 和 args 里面的字段
+func NewJSONLogger(w io.Writer, level Level) Logger {
+	return &jsonLogger{
+		mutex: &sync.Mutex{},
+		w:     w,
+		level: level,
+	}
+}
+
+type jsonLogger struct {
+	// With 出来的 Logger 共用同一把锁，保证每一行是完整的
+	mutex  *sync.Mutex
+	w      io.Writer
+	level  Level
+	fields []any
+}
+
+func (l *jsonLogger) Debug(msg string, args ...any) {
+	l.log(LevelDebug, msg, args)
+}
+
+func (l *jsonLogger) Info(msg string, args ...any) {
+	l.log(LevelInfo, msg, args)
+}
+
+func (l *jsonLogger) Warn(msg string, args ...any) {
+	l.log(LevelWarn, msg, args)
+}
+
+func (l *jsonLogger) Error(msg string, args ...any) {
+	l.log(LevelError, msg, args)
+}
+
+func (l *jsonLogger) With(args ...any) Logger {
+	fields := make([]any, 0, len(l.fields)+len(args))
+	fields = append(fields, l.fields...)
+	fields = append(fields, args...)
+	return &jsonLogger{mutex: l.mutex, w: l.w, level: l.level, fields: fields}
+}
+
+func (l *jsonLogger) log(level Level, msg string, args []any) {
+	if level < l.level {
+		return
+	}
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	writeJSONValue(&buf, time.Now().Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSONValue(&buf, level.String())
+	buf.WriteString(`,"msg":`)
+	writeJSONValue(&buf, msg)
+	writeJSONFields(&buf, l.fields)
+	writeJSONFields(&buf, args)
+	buf.WriteString("}\n")
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	_, _ = l.w.Write(buf.Bytes())
+}
+
+// writeJSONFields 和 slog 一样，落单的 value 用 !BADKEY 作为 key
+func writeJSONFields(buf *bytes.Buffer, args []any) {
+	for i := 0; i < len(args); i += 2 {
+		key, ok := args[i].(string)
+		val := args[i]
+		if ok && i+1 < len(args) {
+			val = args[i+1]
+		} else {
+			key = "!BADKEY"
+			i--
+		}
+		buf.WriteByte(',')
+		writeJSONValue(buf, key)
+		buf.WriteByte(':')
+		writeJSONValue(buf, val)
+	}
+}
+
+func writeJSONValue(buf *bytes.Buffer, val any) {
+	switch v := val.(type) {
+	case error:
+		val = v.Error()
+	case fmt.Stringer:
+		val = v.String()
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprintf("%+v", val))
+	}
+	buf.Write(data)
+}
+
+// RequestID 优先使用请求头里面的 X-Request-ID，没有的话生成一个
+func (c *Context) RequestID() string {
+	if c.requestID == "" {
+		if c.Req != nil {
+			c.requestID = c.Req.Header.Get("X-Request-ID")
+		}
+		if c.requestID == "" {
+			var id [8]byte
+			_, _ = rand.Read(id[:])
+			c.requestID = hex.EncodeToString(id[:])
+		}
+	}
+	return c.requestID
+}
+
+// AddLogFields 给 ctx.Logger() 增加字段，例如 opentelemetry 的 middleware 会加上 trace_id
+func (c *Context) AddLogFields(args ...any) {
+	c.logFields = append(c.logFields, args...)
+}
+
+// Logger 返回当前请求的 Logger，带着 request_id、route 以及 AddLogFields 增加的字段
+// route 在路由匹配之前是空的
+func (c *Context) Logger() Logger {
+	logger := c.logger
+	if logger == nil {
+		logger = defaultLogger
+	}
+	fields := make([]any, 0, 4+len(c.logFields))
+	fields = append(fields, "request_id", c.RequestID(), "route", c.MatchedRoute)
+	fields = append(fields, c.logFields...)
+	return logger.With(fields...)
+}
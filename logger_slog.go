@@ -0,0 +1,37 @@
+//go:build go1.21
+
+package web
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogLogger 把 slog.Logger 适配成 Logger，日志的格式和输出由 slog 的 Handler 决定
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return slogLogger{logger: logger}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (s slogLogger) Debug(msg string, args ...any) {
+	s.logger.Log(context.Background(), slog.LevelDebug, msg, args...)
+}
+
+func (s slogLogger) Info(msg string, args ...any) {
+	s.logger.Log(context.Background(), slog.LevelInfo, msg, args...)
+}
+
+func (s slogLogger) Warn(msg string, args ...any) {
+	s.logger.Log(context.Background(), slog.LevelWarn, msg, args...)
+}
+
+func (s slogLogger) Error(msg string, args ...any) {
+	s.logger.Log(context.Background(), slog.LevelError, msg, args...)
+}
+
+func (s slogLogger) With(args ...any) Logger {
+	return slogLogger{logger: s.logger.With(args...)}
+}
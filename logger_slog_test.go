@@ -0,0 +1,25 @@
+//go:build go1.21
+
+package web
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlogLogger(t *testing.T) {
+	buf := &lockedBuffer{}
+	logger := NewSlogLogger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	logger.Debug("ignored")
+	logger.With("request_id", "req-1").Warn("slow", "cost", 3)
+
+	lines := buf.lines(t)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "WARN", lines[0]["level"])
+	assert.Equal(t, "slow", lines[0]["msg"])
+	assert.Equal(t, "req-1", lines[0]["request_id"])
+	assert.Equal(t, float64(3), lines[0]["cost"])
+}
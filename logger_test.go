@@ -0,0 +1,138 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lockedBuffer 是并发安全的 bytes.Buffer，用来收集日志
+type lockedBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+// lines 把每一行日志解析成 map
+func (b *lockedBuffer) lines(t *testing.T) []map[string]any {
+	var res []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		entry := map[string]any{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+		res = append(res, entry)
+	}
+	return res
+}
+
+func TestJSONLogger(t *testing.T) {
+	testCases := []struct {
+		name    string
+		level   Level
+		log     func(l Logger)
+		wantLen int
+		want    map[string]any
+	}{
+		{
+			name:  "fields",
+			level: LevelInfo,
+			log: func(l Logger) {
+				l.With("app", "web").Info("hello", "code", 200, "err", errors.New("boom"))
+			},
+			wantLen: 1,
+			want: map[string]any{
+				"level": "INFO",
+				"msg":   "hello",
+				"app":   "web",
+				"code":  float64(200),
+				"err":   "boom",
+			},
+		},
+		{
+			name:  "below level",
+			level: LevelWarn,
+			log: func(l Logger) {
+				l.Debug("debug")
+				l.Info("info")
+			},
+		},
+		{
+			name:  "bad key",
+			level: LevelDebug,
+			log: func(l Logger) {
+				l.Error("odd", "key")
+			},
+			wantLen: 1,
+			want: map[string]any{
+				"level":   "ERROR",
+				"msg":     "odd",
+				"!BADKEY": "key",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &lockedBuffer{}
+			tc.log(NewJSONLogger(buf, tc.level))
+			lines := buf.lines(t)
+			require.Len(t, lines, tc.wantLen)
+			if tc.wantLen == 0 {
+				return
+			}
+			assert.NotEmpty(t, lines[0]["time"])
+			for k, v := range tc.want {
+				assert.Equal(t, v, lines[0][k], k)
+			}
+		})
+	}
+}
+
+func TestContext_Logger(t *testing.T) {
+	buf := &lockedBuffer{}
+	server := NewHTTPServer(ServerWithLogger(NewJSONLogger(buf, LevelDebug)))
+	server.Get("/user/:id", func(ctx *Context) {
+		ctx.AddLogFields("trace_id", "abc")
+		ctx.Logger().Info("handled")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/user/1", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	server.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := buf.lines(t)
+	require.Len(t, lines, 2)
+	// 注册路由
+	assert.Equal(t, "DEBUG", lines[0]["level"])
+	assert.Equal(t, "/user/:id", lines[0]["path"])
+	assert.Equal(t, "handled", lines[1]["msg"])
+	assert.Equal(t, "req-1", lines[1]["request_id"])
+	assert.Equal(t, "/user/:id", lines[1]["route"])
+	assert.Equal(t, "abc", lines[1]["trace_id"])
+
+	// 没有 X-Request-ID 的时候生成一个，同一个请求里面保持不变
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/", nil)}
+	id := ctx.RequestID()
+	assert.Len(t, id, 16)
+	assert.Equal(t, id, ctx.RequestID())
+}
@@ -0,0 +1,87 @@
+package web
+
+import "fmt"
+
+// globalMiddleware 是全局的 middleware，name 为空的是匿名的，不能作为插入的位置
+type globalMiddleware struct {
+	name string
+	mdl  Middleware
+}
+
+// ServerWithNamedMiddleware 和 UseGlobalNamed 一样追加一个有名字的全局 middleware
+// 和 ServerWithMiddleware 不同，它不会覆盖之前的设置，不同的包提供的 option 可以组合使用
+func ServerWithNamedMiddleware(name string, mdl Middleware) HTTPServerOption {
+	return func(server *HttpServer) {
+		server.UseGlobalNamed(name, mdl)
+	}
+}
+
+// UseGlobal 在已有的全局 middleware 后面追加 mdls，越早注册的越在外层，mdls 里面有 nil 的时候 panic
+// 匿名的 middleware 没有办法判断是不是重复注册（函数不能比较），需要去重的请使用 UseGlobalNamed
+// 所有的 UseGlobal 方法都要在 Start 之前调用，不能和请求并发执行
+func (h *HttpServer) UseGlobal(mdls ...Middleware) {
+	for i, mdl := range mdls {
+		if mdl == nil {
+			panic(fmt.Sprintf("web: 第 %d 个全局 middleware 是 nil", i+1))
+		}
+	}
+	for _, mdl := range mdls {
+		h.mdls = append(h.mdls, globalMiddleware{mdl: mdl})
+	}
+	h.chain.Store(nil)
+}
+
+// UseGlobalNamed 追加一个有名字的全局 middleware，名字重复的时候 panic
+func (h *HttpServer) UseGlobalNamed(name string, mdl Middleware) {
+	h.insertGlobal(len(h.mdls), name, mdl)
+}
+
+// UseGlobalBefore 把 mdl 插入到名字是 target 的 middleware 前面，也就是它的外层
+// target 不存在或者 name 重复的时候 panic
+func (h *HttpServer) UseGlobalBefore(target string, name string, mdl Middleware) {
+	h.insertGlobal(h.globalIndex(target), name, mdl)
+}
+
+// UseGlobalAfter 把 mdl 插入到名字是 target 的 middleware 后面，也就是它的内层
+// target 不存在或者 name 重复的时候 panic
+func (h *HttpServer) UseGlobalAfter(target string, name string, mdl Middleware) {
+	h.insertGlobal(h.globalIndex(target)+1, name, mdl)
+}
+
+// GlobalMiddlewares 按照执行顺序返回有名字的全局 middleware，方便排查顺序问题
+func (h *HttpServer) GlobalMiddlewares() []string {
+	res := make([]string, 0, len(h.mdls))
+	for _, m := range h.mdls {
+		if m.name != "" {
+			res = append(res, m.name)
+		}
+	}
+	return res
+}
+
+func (h *HttpServer) insertGlobal(idx int, name string, mdl Middleware) {
+	if name == "" {
+		panic("web: 全局 middleware 的名字不能为空，匿名的请使用 UseGlobal")
+	}
+	if mdl == nil {
+		panic(fmt.Sprintf("web: 全局 middleware [%s] 是 nil", name))
+	}
+	for _, m := range h.mdls {
+		if m.name == name {
+			panic(fmt.Sprintf("web: 全局 middleware [%s] 重复注册", name))
+		}
+	}
+	h.mdls = append(h.mdls, globalMiddleware{})
+	copy(h.mdls[idx+1:], h.mdls[idx:])
+	h.mdls[idx] = globalMiddleware{name: name, mdl: mdl}
+	h.chain.Store(nil)
+}
+
+func (h *HttpServer) globalIndex(name string) int {
+	for i, m := range h.mdls {
+		if m.name != "" && m.name == name {
+			return i
+		}
+	}
+	panic(fmt.Sprintf("web: 全局 middleware [%s] 不存在", name))
+}
@@ -0,0 +1,136 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpServer_UseGlobal(t *testing.T) {
+	var logs []string
+	mdl := func(name string) Middleware {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				logs = append(logs, name)
+				next(ctx)
+			}
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		opts      []HTTPServerOption
+		register  func(server *HttpServer)
+		wantNames []string
+		wantLogs  []string
+		wantPanic string
+	}{
+		{
+			name: "append",
+			opts: []HTTPServerOption{
+				ServerWithNamedMiddleware("platform", mdl("platform")),
+				ServerWithNamedMiddleware("app", mdl("app")),
+			},
+			register: func(server *HttpServer) {
+				server.UseGlobal(mdl("anonymous"))
+			},
+			wantNames: []string{"platform", "app"},
+			wantLogs:  []string{"platform", "app", "anonymous"},
+		},
+		{
+			name: "before and after",
+			register: func(server *HttpServer) {
+				server.UseGlobalNamed("recover", mdl("recover"))
+				server.UseGlobalNamed("accesslog", mdl("accesslog"))
+				server.UseGlobalBefore("recover", "trace", mdl("trace"))
+				server.UseGlobalAfter("recover", "timeout", mdl("timeout"))
+				server.UseGlobalAfter("accesslog", "auth", mdl("auth"))
+			},
+			wantNames: []string{"trace", "recover", "timeout", "accesslog", "auth"},
+			wantLogs:  []string{"trace", "recover", "timeout", "accesslog", "auth"},
+		},
+		{
+			name: "overwrite",
+			opts: []HTTPServerOption{
+				ServerWithNamedMiddleware("platform", mdl("platform")),
+				ServerWithMiddleware(mdl("app")),
+			},
+			wantNames: []string{},
+			wantLogs:  []string{"app"},
+		},
+		{
+			name: "duplicate",
+			register: func(server *HttpServer) {
+				server.UseGlobalNamed("recover", mdl("recover"))
+				server.UseGlobalAfter("recover", "recover", mdl("recover"))
+			},
+			wantPanic: "web: 全局 middleware [recover] 重复注册",
+		},
+		{
+			name: "target not found",
+			register: func(server *HttpServer) {
+				server.UseGlobalBefore("recover", "trace", mdl("trace"))
+			},
+			wantPanic: "web: 全局 middleware [recover] 不存在",
+		},
+		{
+			name: "nil anonymous",
+			register: func(server *HttpServer) {
+				server.UseGlobal(mdl("recover"), nil)
+			},
+			wantPanic: "web: 第 2 个全局 middleware 是 nil",
+		},
+		{
+			name: "nil named",
+			register: func(server *HttpServer) {
+				server.UseGlobalNamed("recover", nil)
+			},
+			wantPanic: "web: 全局 middleware [recover] 是 nil",
+		},
+		{
+			name: "empty name",
+			register: func(server *HttpServer) {
+				server.UseGlobalNamed("", mdl("recover"))
+			},
+			wantPanic: "web: 全局 middleware 的名字不能为空，匿名的请使用 UseGlobal",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logs = nil
+			server := NewHTTPServer(tc.opts...)
+			server.Get("/", func(ctx *Context) {})
+			if tc.wantPanic != "" {
+				assert.PanicsWithValue(t, tc.wantPanic, func() {
+					tc.register(server)
+				})
+				return
+			}
+			if tc.register != nil {
+				tc.register(server)
+			}
+			assert.Equal(t, tc.wantNames, server.GlobalMiddlewares())
+			server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, tc.wantLogs, logs)
+		})
+	}
+}
+
+func TestHttpServer_UseGlobal_Rebuild(t *testing.T) {
+	var logs []string
+	server := NewHTTPServer()
+	server.Get("/", func(ctx *Context) {})
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// 链条已经组装过了，再注册的 middleware 也要生效
+	server.UseGlobalNamed("late", func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			logs = append(logs, "late")
+			next(ctx)
+		}
+	})
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, []string{"late"}, logs)
+}
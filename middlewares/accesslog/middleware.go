@@ -25,6 +25,9 @@ func (m MiddlewareBuilder) Build() web.Middleware {
 					Route: ctx.MatchedRoute,
 					Path: ctx.Req.URL.Path,
 					HttpMethod: ctx.Req.Method,
+					Status: ctx.RespStatusCode,
+					Size: ctx.RespSize(),
+					TimedOut: ctx.TimedOut,
 				}
 				data, _ := json.Marshal(access)
 				m.logFunc(string(data))
@@ -39,4 +42,7 @@ type accessLog struct {
 	Route      string `json:"route"`
 	Path       string `json:"path"`
 	HttpMethod string `json:"http_method"`
+	Status     int    `json:"status"`
+	Size       int    `json:"size"`
+	TimedOut   bool   `json:"timed_out,omitempty"`
 }
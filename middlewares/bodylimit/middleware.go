@@ -0,0 +1,62 @@
+package bodylimit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"web"
+)
+
+// MiddlewareBuilder 限制请求体的大小，一般注册在路由上，例如上传的接口放宽，其它的接口收紧
+// Content-Length 超过限制的直接返回 413，没有 Content-Length 的在读取超过限制的时候返回 413
+type MiddlewareBuilder struct {
+	maxBytes int64
+}
+
+func NewMiddlewareBuilder(maxBytes int64) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		maxBytes: maxBytes,
+	}
+}
+
+func (m MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			if ctx.Req.ContentLength > m.maxBytes {
+				tooLarge(ctx)
+				return
+			}
+			if ctx.Req.Body == nil || ctx.Req.Body == http.NoBody {
+				next(ctx)
+				return
+			}
+			body := &limitedBody{ReadCloser: http.MaxBytesReader(ctx.Resp, ctx.Req.Body, m.maxBytes)}
+			ctx.Req.Body = body
+			next(ctx)
+			// handler 读到了超过限制的部分，不管它怎么处理的这个错误，都以 413 为准
+			if body.exceeded && !ctx.RespWritten() {
+				tooLarge(ctx)
+			}
+		}
+	}
+}
+
+func tooLarge(ctx *web.Context) {
+	ctx.RespStatusCode = http.StatusRequestEntityTooLarge
+	ctx.RespData = []byte(http.StatusText(http.StatusRequestEntityTooLarge))
+}
+
+// limitedBody 记录下有没有超过限制
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	n, err := l.ReadCloser.Read(p)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		l.exceeded = true
+	}
+	return n, err
+}
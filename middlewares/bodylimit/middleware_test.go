@@ -0,0 +1,79 @@
+package bodylimit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"web"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	server := web.NewHTTPServer()
+	handler := func(ctx *web.Context) {
+		data, err := io.ReadAll(ctx.Req.Body)
+		if err != nil {
+			ctx.RespStatusCode = http.StatusBadRequest
+			return
+		}
+		ctx.RespData = data
+	}
+	server.POST("/small", handler, NewMiddlewareBuilder(4).Build())
+	server.POST("/large", handler, NewMiddlewareBuilder(1024).Build())
+
+	testCases := []struct {
+		name          string
+		path          string
+		body          string
+		contentLength int64
+		wantCode      int
+		wantBody      string
+	}{
+		{
+			name:          "within limit",
+			path:          "/small",
+			body:          "abcd",
+			contentLength: 4,
+			wantCode:      http.StatusOK,
+			wantBody:      "abcd",
+		},
+		{
+			name:          "content length too large",
+			path:          "/small",
+			body:          "abcde",
+			contentLength: 5,
+			wantCode:      http.StatusRequestEntityTooLarge,
+			wantBody:      "Request Entity Too Large",
+		},
+		{
+			// chunked 的请求在读的时候才发现
+			name:          "chunked too large",
+			path:          "/small",
+			body:          "abcde",
+			contentLength: -1,
+			wantCode:      http.StatusRequestEntityTooLarge,
+			wantBody:      "Request Entity Too Large",
+		},
+		{
+			name:          "other route",
+			path:          "/large",
+			body:          "abcde",
+			contentLength: 5,
+			wantCode:      http.StatusOK,
+			wantBody:      "abcde",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, io.NopCloser(strings.NewReader(tc.body)))
+			req.ContentLength = tc.contentLength
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+			assert.Equal(t, tc.wantBody, resp.Body.String())
+		})
+	}
+}
@@ -5,11 +5,23 @@ import "web"
 type MiddlewareBuilder struct {
 	//这种设计只能返回固定的值，不能动态渲染页面  key是状态码
 	resp map[int][]byte
+	// 需要动态渲染的页面，用 HttpServer 上的模板引擎渲染，key是状态码，value是模板名字
+	tpls map[int]string
+}
+
+// ErrorPage 是渲染错误页面时传给模板的数据
+type ErrorPage struct {
+	StatusCode int
+	// Path 是出错的请求路径
+	Path string
+	// Msg 是原本的响应数据
+	Msg string
 }
 
 func NewMiddlewareBuilder() *MiddlewareBuilder {
 	return &MiddlewareBuilder{
 		resp: make(map[int][]byte),
+		tpls: make(map[int]string),
 	}
 }
 
@@ -18,10 +30,31 @@ func (m *MiddlewareBuilder) AddCode(status int, data []byte) *MiddlewareBuilder
 	return m
 }
 
+// AddTemplate 用模板 tplName 渲染 status 的错误页面，优先于 AddCode
+// 需要通过 web.ServerWithTemplateEngine 设置模板引擎，渲染失败的时候退回到 AddCode 或者原本的响应
+func (m *MiddlewareBuilder) AddTemplate(status int, tplName string) *MiddlewareBuilder {
+	m.tpls[status] = tplName
+	return m
+}
+
 func (m MiddlewareBuilder) Build() web.Middleware {
 	return func(next web.HandleFunc) web.HandleFunc {
 		return func(ctx *web.Context) {
 			next(ctx)
+			// 已经直接写到 Resp 里面了，篡改不了
+			if ctx.RespWritten() {
+				return
+			}
+			if tplName, ok := m.tpls[ctx.RespStatusCode]; ok {
+				err := ctx.RenderTemplate(ctx.RespStatusCode, tplName, ErrorPage{
+					StatusCode: ctx.RespStatusCode,
+					Path:       ctx.Req.URL.Path,
+					Msg:        string(ctx.RespData),
+				})
+				if err == nil {
+					return
+				}
+			}
 			if resp, ok := m.resp[ctx.RespStatusCode]; ok {
 				//篡改返回结果
 				ctx.RespData = resp
@@ -2,8 +2,13 @@ package errhdl
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"testing/fstest"
 	"web"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMiddleware_Builder(t *testing.T) {
@@ -24,3 +29,29 @@ func TestMiddleware_Builder(t *testing.T) {
 	server := web.NewHTTPServer(web.ServerWithMiddleware(builder.Build()))
 	server.Start(":8081")
 }
+
+func TestMiddlewareBuilder_AddTemplate(t *testing.T) {
+	engine, err := web.NewGoTemplateEngine(fstest.MapFS{
+		"404.gohtml": {Data: []byte(`<h1>{{.Path}} 走失了</h1>`)},
+	}, "*.gohtml")
+	require.NoError(t, err)
+	builder := NewMiddlewareBuilder().
+		AddTemplate(http.StatusNotFound, "404.gohtml").
+		// 模板不存在的时候退回到固定的页面
+		AddTemplate(http.StatusBadRequest, "400.gohtml").
+		AddCode(http.StatusBadRequest, []byte("请求不对"))
+	server := web.NewHTTPServer(web.ServerWithMiddleware(builder.Build()), web.ServerWithTemplateEngine(engine))
+	server.Get("/bad", func(ctx *web.Context) {
+		ctx.RespStatusCode = http.StatusBadRequest
+	})
+
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/user", nil))
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, "<h1>/user 走失了</h1>", resp.Body.String())
+
+	resp = httptest.NewRecorder()
+	server.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/bad", nil))
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, "请求不对", resp.Body.String())
+}
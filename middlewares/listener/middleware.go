@@ -0,0 +1,36 @@
+package listener
+
+import (
+	"net/http"
+	"web"
+)
+
+// MiddlewareBuilder 限制路由只能通过某些 listener 访问，例如 /metrics、pprof 只在对内的端口上开放
+// 其它 listener 上的请求返回 404，和没有注册这个路由一样
+type MiddlewareBuilder struct {
+	names map[string]struct{}
+}
+
+// NewMiddlewareBuilder names 是 web.ServerWithListener 里面的名字，Start 的地址是 web.DefaultListener
+func NewMiddlewareBuilder(names ...string) *MiddlewareBuilder {
+	res := &MiddlewareBuilder{
+		names: make(map[string]struct{}, len(names)),
+	}
+	for _, name := range names {
+		res.names[name] = struct{}{}
+	}
+	return res
+}
+
+func (m MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			if _, ok := m.names[ctx.ListenerName()]; !ok {
+				ctx.RespStatusCode = http.StatusNotFound
+				ctx.RespData = []byte("Not Found")
+				return
+			}
+			next(ctx)
+		}
+	}
+}
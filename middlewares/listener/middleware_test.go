@@ -0,0 +1,96 @@
+package listener
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+	"web"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	// 先拿到两个空闲的端口
+	public, admin := freeAddr(t), freeAddr(t)
+	server := web.NewHTTPServer(web.ServerWithListener("admin", admin))
+	server.Get("/hello", func(ctx *web.Context) {
+		ctx.RespData = []byte("hello " + ctx.ListenerName())
+	})
+	server.Get("/metrics", func(ctx *web.Context) {
+		ctx.RespData = []byte("metrics")
+	}, NewMiddlewareBuilder("admin").Build())
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Start(public)
+	}()
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", admin)
+		if err != nil {
+			return false
+		}
+		_ = conn.Close()
+		return true
+	}, time.Second, 10*time.Millisecond)
+
+	testCases := []struct {
+		name     string
+		url      string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "public hello",
+			url:      "http://" + public + "/hello",
+			wantCode: http.StatusOK,
+			wantBody: "hello default",
+		},
+		{
+			name:     "admin hello",
+			url:      "http://" + admin + "/hello",
+			wantCode: http.StatusOK,
+			wantBody: "hello admin",
+		},
+		{
+			name:     "public metrics",
+			url:      "http://" + public + "/metrics",
+			wantCode: http.StatusNotFound,
+			wantBody: "Not Found",
+		},
+		{
+			name:     "admin metrics",
+			url:      "http://" + admin + "/metrics",
+			wantCode: http.StatusOK,
+			wantBody: "metrics",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := http.Get(tc.url)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantCode, resp.StatusCode)
+			assert.Equal(t, tc.wantBody, string(body))
+		})
+	}
+
+	// 所有的 listener 都关闭了
+	require.NoError(t, server.Shutdown(context.Background()))
+	assert.Equal(t, http.ErrServerClosed, <-served)
+	for _, addr := range []string{public, admin} {
+		_, err := net.Dial("tcp", addr)
+		assert.Error(t, err)
+	}
+}
+
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return ln.Addr().String()
+}
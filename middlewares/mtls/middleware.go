@@ -0,0 +1,83 @@
+package mtls
+
+import (
+	"net/http"
+	"web"
+)
+
+// MiddlewareBuilder 按照 mTLS 客户端证书的身份做访问控制，一般注册在路由上
+// 每一个 RequireXXX 都必须满足，同一个 RequireXXX 里面的多个值满足一个就可以
+// 没有带证书返回 401，不满足条件返回 403
+type MiddlewareBuilder struct {
+	policies []func(id *web.ClientIdentity) bool
+}
+
+func NewMiddlewareBuilder() *MiddlewareBuilder {
+	return &MiddlewareBuilder{}
+}
+
+// RequireOU 要求证书的 OU 是 ous 中的一个，例如 RequireOU("payments")
+func (m *MiddlewareBuilder) RequireOU(ous ...string) *MiddlewareBuilder {
+	return m.Require(func(id *web.ClientIdentity) bool {
+		return containsAny(id.Subject.OrganizationalUnit, ous)
+	})
+}
+
+// RequireCommonName 要求证书的 CN 是 names 中的一个
+func (m *MiddlewareBuilder) RequireCommonName(names ...string) *MiddlewareBuilder {
+	return m.Require(func(id *web.ClientIdentity) bool {
+		return containsAny([]string{id.Subject.CommonName}, names)
+	})
+}
+
+// RequireDNSName 要求证书的 DNS SAN 里面包含 names 中的一个
+func (m *MiddlewareBuilder) RequireDNSName(names ...string) *MiddlewareBuilder {
+	return m.Require(func(id *web.ClientIdentity) bool {
+		return containsAny(id.DNSNames, names)
+	})
+}
+
+// RequireSPIFFEID 要求证书的 SPIFFE ID 是 ids 中的一个
+func (m *MiddlewareBuilder) RequireSPIFFEID(ids ...string) *MiddlewareBuilder {
+	return m.Require(func(id *web.ClientIdentity) bool {
+		return id.SPIFFEID != "" && containsAny([]string{id.SPIFFEID}, ids)
+	})
+}
+
+// Require 自定义的条件
+func (m *MiddlewareBuilder) Require(policy func(id *web.ClientIdentity) bool) *MiddlewareBuilder {
+	m.policies = append(m.policies, policy)
+	return m
+}
+
+func (m MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			id, ok := ctx.ClientIdentity()
+			if !ok {
+				ctx.RespStatusCode = http.StatusUnauthorized
+				ctx.RespData = []byte("需要客户端证书")
+				return
+			}
+			for _, policy := range m.policies {
+				if !policy(id) {
+					ctx.RespStatusCode = http.StatusForbidden
+					ctx.RespData = []byte("客户端证书没有权限")
+					return
+				}
+			}
+			next(ctx)
+		}
+	}
+}
+
+func containsAny(vals []string, targets []string) bool {
+	for _, val := range vals {
+		for _, target := range targets {
+			if val == target {
+				return true
+			}
+		}
+	}
+	return false
+}
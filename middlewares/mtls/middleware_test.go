@@ -0,0 +1,77 @@
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"web"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.org/ns/payments/sa/api")
+	payments := &x509.Certificate{
+		Subject: pkix.Name{CommonName: "api", OrganizationalUnit: []string{"payments"}},
+		URIs:    []*url.URL{spiffe},
+	}
+	orders := &x509.Certificate{
+		Subject: pkix.Name{CommonName: "api", OrganizationalUnit: []string{"orders"}},
+	}
+	testCases := []struct {
+		name     string
+		builder  *MiddlewareBuilder
+		cert     *x509.Certificate
+		wantCode int
+	}{
+		{
+			name:     "no cert",
+			builder:  NewMiddlewareBuilder().RequireOU("payments"),
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "ou",
+			builder:  NewMiddlewareBuilder().RequireOU("payments", "billing"),
+			cert:     payments,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "wrong ou",
+			builder:  NewMiddlewareBuilder().RequireOU("payments"),
+			cert:     orders,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			// 所有的条件都要满足
+			name:     "ou and spiffe",
+			builder:  NewMiddlewareBuilder().RequireCommonName("api").RequireSPIFFEID("spiffe://example.org/ns/payments/sa/api"),
+			cert:     payments,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "no spiffe",
+			builder:  NewMiddlewareBuilder().RequireCommonName("api").RequireSPIFFEID("spiffe://example.org/ns/payments/sa/api"),
+			cert:     orders,
+			wantCode: http.StatusForbidden,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := web.NewHTTPServer()
+			server.Get("/pay", func(ctx *web.Context) {
+				ctx.RespStatusCode = http.StatusOK
+			}, tc.builder.Build())
+			req := httptest.NewRequest(http.MethodGet, "/pay", nil)
+			if tc.cert != nil {
+				req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{tc.cert}}}
+			}
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+		})
+	}
+}
@@ -47,6 +47,10 @@ func (m MiddlewareBuilder) Build() web.Middleware {
 			defer span.End()
 
 			ctx.Req = ctx.Req.WithContext(reqCtx)
+			// ctx.Logger() 打出来的日志带上 trace_id，方便和链路关联起来
+			if sc := span.SpanContext(); sc.HasTraceID() {
+				ctx.AddLogFields("trace_id", sc.TraceID().String())
+			}
 
 			//二话不说 先执行next
 			next(ctx)
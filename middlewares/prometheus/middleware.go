@@ -30,7 +30,15 @@ func (m MiddlewareBuilder) Build() web.Middleware {
 		},
 	}, []string{"pattern", "method", "status"})
 
-	prometheus.MustRegister(vector)
+	// 超时的请求单独计数，见 middlewares/timeout
+	timeouts := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: m.Namespace,
+		Subsystem: m.Subsystem,
+		Name:      m.Name + "_timeouts_total",
+		Help:      "超时的请求数",
+	}, []string{"pattern", "method"})
+
+	prometheus.MustRegister(vector, timeouts)
 
 	return func(next web.HandleFunc) web.HandleFunc {
 		return func(ctx *web.Context) {
@@ -43,6 +51,9 @@ func (m MiddlewareBuilder) Build() web.Middleware {
 				}
 				vector.WithLabelValues(pattern, ctx.Req.Method,
 					strconv.Itoa(ctx.RespStatusCode)).Observe(float64(duration))
+				if ctx.TimedOut {
+					timeouts.WithLabelValues(pattern, ctx.Req.Method).Inc()
+				}
 			}()
 			next(ctx)
 		}
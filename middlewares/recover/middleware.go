@@ -1,11 +1,16 @@
 package recover
 
-import "web"
+import (
+	"fmt"
+	"runtime/debug"
+	"web"
+)
 
 type MiddlewareBuilder struct {
 	StatusCode int
 	Data       []byte
-	Log        func(ctx *web.Context)
+	// Log 为 nil 的时候用 ctx.Logger() 记录 panic 和调用栈
+	Log func(ctx *web.Context)
 }
 
 func (m MiddlewareBuilder) Build() web.Middleware {
@@ -15,7 +20,11 @@ func (m MiddlewareBuilder) Build() web.Middleware {
 				if err := recover(); err != nil {
 					ctx.RespStatusCode = m.StatusCode
 					ctx.RespData = m.Data
-					m.Log(ctx)
+					if m.Log != nil {
+						m.Log(ctx)
+						return
+					}
+					ctx.Logger().Error("panic", "panic", fmt.Sprint(err), "stack", string(debug.Stack()))
 				}
 			}()
 			next(ctx)
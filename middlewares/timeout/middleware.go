@@ -0,0 +1,146 @@
+package timeout
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+	"web"
+)
+
+// MiddlewareBuilder 给请求设置超时时间，一般注册在路由上
+// handler 在另外的 goroutine 里面执行，拿到的是 Context 的副本，Req.Context() 带着超时时间
+// 超时之后立刻返回 StatusCode 和 Body，并且设置 ctx.TimedOut
+// handler 之后再写 RespData 或者 Resp 都不会影响已经返回的响应，所以 handler 应该根据 ctx.Done() 尽快退出
+// 直接写 Resp 的数据会先缓存起来，所以 Stream、SSE、WebSocket 不要使用这个 middleware
+type MiddlewareBuilder struct {
+	timeout    time.Duration
+	statusCode int
+	body       []byte
+}
+
+func NewMiddlewareBuilder(timeout time.Duration) *MiddlewareBuilder {
+	return &MiddlewareBuilder{
+		timeout:    timeout,
+		statusCode: http.StatusServiceUnavailable,
+		body:       []byte(http.StatusText(http.StatusServiceUnavailable)),
+	}
+}
+
+// StatusCode 超时的时候返回的状态码，默认是 503，作为网关的时候可以用 504
+func (m *MiddlewareBuilder) StatusCode(code int) *MiddlewareBuilder {
+	m.statusCode = code
+	return m
+}
+
+// Body 超时的时候返回的数据
+func (m *MiddlewareBuilder) Body(data []byte) *MiddlewareBuilder {
+	m.body = data
+	return m
+}
+
+func (m MiddlewareBuilder) Build() web.Middleware {
+	return func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			reqCtx, cancel := context.WithTimeout(ctx.Req.Context(), m.timeout)
+			defer cancel()
+
+			tw := &timeoutWriter{ctx: reqCtx, header: ctx.Resp.Header().Clone()}
+			cp := ctx.Clone(tw)
+			cp.Req = ctx.Req.WithContext(reqCtx)
+			done := make(chan struct{})
+			panicCh := make(chan any, 1)
+			go func() {
+				defer func() {
+					// handler 结束了，上传的文件就可以删掉了
+					_ = cp.RemoveUploadedFiles()
+					if p := recover(); p != nil {
+						panicCh <- p
+					}
+				}()
+				next(cp)
+				close(done)
+			}()
+
+			select {
+			case <-done:
+				tw.mutex.Lock()
+				defer tw.mutex.Unlock()
+				tw.flushTo(ctx.Resp)
+				ctx.RespStatusCode = cp.RespStatusCode
+				ctx.RespData = cp.RespData
+				ctx.RespErr = cp.RespErr
+				ctx.PathParams = cp.PathParams
+				ctx.MatchedRoute = cp.MatchedRoute
+			case p := <-panicCh:
+				// 交给外面的 recover middleware 处理
+				panic(p)
+			case <-reqCtx.Done():
+				tw.mutex.Lock()
+				defer tw.mutex.Unlock()
+				tw.timedOut = true
+				// 客户端自己断开的不算超时
+				if reqCtx.Err() == context.DeadlineExceeded {
+					ctx.TimedOut = true
+				}
+				ctx.RespStatusCode = m.statusCode
+				ctx.RespData = m.body
+			}
+		}
+	}
+}
+
+// timeoutWriter 缓存 handler 直接写到 Resp 的数据，没有超时的时候再写到真正的 Resp 里面
+type timeoutWriter struct {
+	// ctx 到期之后 middleware 可能还没来得及设置 timedOut，所以也要检查 ctx
+	ctx         context.Context
+	mutex       sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.timedOut || w.ctx.Err() != nil {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !w.wroteHeader {
+		w.writeHeaderLocked(http.StatusOK)
+	}
+	return w.buf.Write(data)
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.timedOut || w.wroteHeader || w.ctx.Err() != nil {
+		return
+	}
+	w.writeHeaderLocked(code)
+}
+
+func (w *timeoutWriter) writeHeaderLocked(code int) {
+	w.wroteHeader = true
+	w.status = code
+}
+
+func (w *timeoutWriter) flushTo(dst http.ResponseWriter) {
+	header := dst.Header()
+	for k, v := range w.header {
+		header[k] = v
+	}
+	if !w.wroteHeader {
+		return
+	}
+	dst.WriteHeader(w.status)
+	_, _ = dst.Write(w.buf.Bytes())
+}
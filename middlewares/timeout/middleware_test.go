@@ -0,0 +1,102 @@
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"web"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	var timedOut bool
+	server := web.NewHTTPServer(web.ServerWithMiddleware(func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			next(ctx)
+			timedOut = ctx.TimedOut
+		}
+	}))
+	lateWrite := make(chan struct{})
+	server.Get("/slow", func(ctx *web.Context) {
+		<-ctx.Done()
+		// 超时之后再写也不会影响已经返回的响应
+		ctx.RespData = []byte("late")
+		_, err := ctx.Resp.Write([]byte("late"))
+		assert.Equal(t, http.ErrHandlerTimeout, err)
+		close(lateWrite)
+	}, NewMiddlewareBuilder(50*time.Millisecond).StatusCode(http.StatusGatewayTimeout).Body([]byte("timeout")).Build())
+	server.Get("/fast/:id", func(ctx *web.Context) {
+		_, ok := ctx.Deadline()
+		assert.True(t, ok)
+		ctx.Resp.Header().Set("X-Id", ctx.PathParams["id"])
+		ctx.RespStatusCode = http.StatusCreated
+		ctx.RespData = []byte("fast")
+	}, NewMiddlewareBuilder(time.Second).Build())
+	server.Get("/write", func(ctx *web.Context) {
+		ctx.Resp.WriteHeader(http.StatusAccepted)
+		_, _ = ctx.Resp.Write([]byte("direct"))
+	}, NewMiddlewareBuilder(time.Second).Build())
+
+	testCases := []struct {
+		name         string
+		path         string
+		wantCode     int
+		wantBody     string
+		wantHeader   string
+		wantTimedOut bool
+	}{
+		{
+			name:         "timeout",
+			path:         "/slow",
+			wantCode:     http.StatusGatewayTimeout,
+			wantBody:     "timeout",
+			wantTimedOut: true,
+		},
+		{
+			name:       "fast",
+			path:       "/fast/12",
+			wantCode:   http.StatusCreated,
+			wantBody:   "fast",
+			wantHeader: "12",
+		},
+		{
+			name:     "write resp",
+			path:     "/write",
+			wantCode: http.StatusAccepted,
+			wantBody: "direct",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.wantCode, resp.Code)
+			assert.Equal(t, tc.wantBody, resp.Body.String())
+			assert.Equal(t, tc.wantHeader, resp.Header().Get("X-Id"))
+			assert.Equal(t, tc.wantTimedOut, timedOut)
+		})
+	}
+	<-lateWrite
+}
+
+func TestMiddlewareBuilder_Panic(t *testing.T) {
+	server := web.NewHTTPServer(web.ServerWithMiddleware(func(next web.HandleFunc) web.HandleFunc {
+		return func(ctx *web.Context) {
+			defer func() {
+				if err := recover(); err != nil {
+					ctx.RespStatusCode = http.StatusInternalServerError
+				}
+			}()
+			next(ctx)
+		}
+	}))
+	server.Get("/panic", func(ctx *web.Context) {
+		panic("boom")
+	}, NewMiddlewareBuilder(time.Second).Build())
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	// panic 交给外面的 recover 处理
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+var (
+	_ http.Flusher  = &responseWriter{}
+	_ http.Hijacker = &responseWriter{}
+	_ io.ReaderFrom = &responseWriter{}
+)
+
+// responseWriter 包装了 http.ResponseWriter，记录下直接写到 Resp 的状态码和字节数
+// 这样即便用户绕开了 RespStatusCode、RespData，middleware 也能拿到真实的结果
+type responseWriter struct {
+	http.ResponseWriter
+
+	status      int
+	size        int
+	wroteHeader bool
+	hijacked    bool
+
+	// Hijack 之后的连接登记到 conns 里面，Shutdown 超时的时候强制关闭
+	conns *connSet
+	conn  net.Conn
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w}
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	// 1xx 可以写多次，101 除外
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	// 和 net/http 一样，重复的 WriteHeader 没有效果，这里直接忽略，避免打印 superfluous 的日志
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+// ReadFrom 保留底层的 io.ReaderFrom，这样 http.ServeContent 依旧可以用上 sendfile
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(w.ResponseWriter, r)
+	}
+	w.size += int(n)
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("web: ResponseWriter 不支持 Hijack")
+	}
+	conn, rw, err := hj.Hijack()
+	if err == nil {
+		w.hijacked = true
+		if w.conns != nil {
+			w.conn = conn
+			w.conns.add(conn)
+		}
+	}
+	return conn, rw, err
+}
+
+// Unwrap 给 http.ResponseController 使用
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
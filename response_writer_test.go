@@ -0,0 +1,99 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseWriter(t *testing.T) {
+	testCases := []struct {
+		name     string
+		handler  HandleFunc
+		wantCode int
+		wantBody string
+		// middleware 看到的结果
+		wantRespCode    int
+		wantRespSize    int
+		wantRespWritten bool
+	}{
+		{
+			name: "write directly",
+			handler: func(ctx *Context) {
+				ctx.Resp.WriteHeader(http.StatusCreated)
+				_, _ = ctx.Resp.Write([]byte("hello"))
+			},
+			wantCode:        http.StatusCreated,
+			wantBody:        "hello",
+			wantRespCode:    http.StatusCreated,
+			wantRespSize:    5,
+			wantRespWritten: true,
+		},
+		{
+			name: "write without header",
+			handler: func(ctx *Context) {
+				_, _ = ctx.Resp.Write([]byte("hello"))
+			},
+			wantCode:        http.StatusOK,
+			wantBody:        "hello",
+			wantRespCode:    http.StatusOK,
+			wantRespSize:    5,
+			wantRespWritten: true,
+		},
+		{
+			name: "resp data",
+			handler: func(ctx *Context) {
+				ctx.RespStatusCode = http.StatusAccepted
+				ctx.RespData = []byte("hello world")
+			},
+			wantCode:     http.StatusAccepted,
+			wantBody:     "hello world",
+			wantRespCode: http.StatusAccepted,
+			wantRespSize: 11,
+		},
+		{
+			name: "direct write wins",
+			handler: func(ctx *Context) {
+				ctx.RespStatusCode = http.StatusOK
+				ctx.Resp.WriteHeader(http.StatusBadRequest)
+				// 重复的 WriteHeader 被忽略
+				ctx.Resp.WriteHeader(http.StatusOK)
+				ctx.Resp.(http.Flusher).Flush()
+			},
+			wantCode:        http.StatusBadRequest,
+			wantRespCode:    http.StatusBadRequest,
+			wantRespWritten: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var code, size int
+			var written bool
+			server := NewHTTPServer(ServerWithMiddleware(func(next HandleFunc) HandleFunc {
+				return func(ctx *Context) {
+					next(ctx)
+					code, size, written = ctx.RespStatusCode, ctx.RespSize(), ctx.RespWritten()
+				}
+			}))
+			server.Get("/user", tc.handler)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/user", nil))
+			assert.Equal(t, tc.wantCode, resp.Code)
+			assert.Equal(t, tc.wantBody, resp.Body.String())
+			assert.Equal(t, tc.wantRespCode, code)
+			assert.Equal(t, tc.wantRespSize, size)
+			assert.Equal(t, tc.wantRespWritten, written)
+		})
+	}
+}
+
+func TestResponseWriter_Hijack(t *testing.T) {
+	// httptest.ResponseRecorder 不支持 Hijack
+	w := newResponseWriter(httptest.NewRecorder())
+	_, _, err := w.Hijack()
+	assert.Error(t, err)
+	assert.False(t, w.hijacked)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 )
 
 type router struct {
@@ -48,6 +49,7 @@ func (r *router) addRoute(method string, path string, handler HandleFunc, mdls .
 			panic("web: 路由冲突[/]")
 		}
 		root.handler = handler
+		root.route = path
 		root.mdls = mdls //增加middleware
 		return
 	}
@@ -64,6 +66,8 @@ func (r *router) addRoute(method string, path string, handler HandleFunc, mdls .
 		panic(fmt.Sprintf("web: 路由冲突[%s]", path))
 	}
 	root.handler = handler
+	// 记录注册时的路由，例如 /user/:id，而不是具体的请求路径
+	root.route = path
 	root.mdls = mdls //增加middleware
 
 }
@@ -77,10 +81,10 @@ func (r *router) findRoute(method string, path string) (*matchInfo, bool) {
 		return nil, false
 	}
 	if path == "/" {
-		root.route = "/"
+		root.resolve(r, root)
 		return &matchInfo{
 			n:    root,
-			mdls: root.mdls,
+			mdls: root.routeMdls,
 		}, true
 	}
 	path = strings.Trim(path, "/")
@@ -120,8 +124,8 @@ func (r *router) findRoute(method string, path string) (*matchInfo, bool) {
 		mi.n = mi_n
 	}
 
-	mi.n.route = path
-	mi.mdls = r.findMdls(root, segs)
+	mi.n.resolve(r, root)
+	mi.mdls = mi.n.routeMdls
 
 	return mi, true
 }
@@ -159,30 +163,27 @@ func (r *router) findMdls(root *node, segs []string) []Middleware {
 		path := segs[layerIndex]
 		for i := 0; i < length; i++ {
 			tmpnode := st.Remove(st.Front()).(*node)
-			// 通配符-》正则路由 -》参数路由-》静态路由
-			if tmpnode.typ == nodeTypeAny && tmpnode.mdls != nil {
-				mdls = append(mdls, tmpnode.mdls...)
-			} else if tmpnode.typ == nodeTypeReg && tmpnode.regExpr.Match([]byte(path))&& tmpnode.mdls != nil{
-				mdls = append(mdls, tmpnode.mdls...)
-			}else if tmpnode.typ == nodeTypeParam && tmpnode.mdls != nil{
-				mdls = append(mdls, tmpnode.mdls...)
-			}else if tmpnode.typ == nodeTypeStatic && tmpnode.path == path && tmpnode.mdls != nil {
+			// 没有匹配上这一段的节点，它和它下面的路由都跟这个请求无关
+			if !tmpnode.matchSeg(path) {
+				continue
+			}
+			if tmpnode.mdls != nil {
 				mdls = append(mdls, tmpnode.mdls...)
 			}
 
+			// 通配符-》正则路由 -》参数路由-》静态路由
 			if tmpnode.starChild != nil {
 				st.PushBack(tmpnode.starChild)
 			}
 
-			if tmpnode.regChild != nil{
+			if tmpnode.regChild != nil {
 				st.PushBack(tmpnode.regChild)
 			}
 
-			if tmpnode.paramChild != nil{
+			if tmpnode.paramChild != nil {
 				st.PushBack(tmpnode.paramChild)
 			}
 
-
 			if tmpnode.children != nil {
 				for _, staticNode := range tmpnode.children {
 					st.PushBack(staticNode)
@@ -195,6 +196,19 @@ func (r *router) findMdls(root *node, segs []string) []Middleware {
 	return mdls
 }
 
+// matchSeg 判断节点能不能匹配路由中的一段，findMdls 使用
+// seg 是注册的路由里面的一段，所以正则路由和自己的 path 一样的时候也算匹配上，而不是拿正则去匹配 :id(reg) 这种文本
+func (n *node) matchSeg(seg string) bool {
+	switch n.typ {
+	case nodeTypeAny, nodeTypeParam:
+		return true
+	case nodeTypeReg:
+		return n.path == seg || n.regExpr.MatchString(seg)
+	default:
+		return n.path == seg
+	}
+}
+
 type nodeType int
 
 const (
@@ -218,7 +232,7 @@ const (
 type node struct {
 	typ nodeType
 
-	//匹配的完整路径
+	//匹配的完整路径，也就是注册的路由，例如 /user/:id
 	route string
 
 	// 路径
@@ -241,6 +255,33 @@ type node struct {
 
 	//middleware
 	mdls []Middleware
+
+	// 作用在这个路由上的所有 middleware，以及包好 middleware 之后的 handler，见 resolve
+	resolveOnce sync.Once
+	routeMdls   []Middleware
+	chain       HandleFunc
+}
+
+// resolve 计算作用在这个路由上的 middleware，并且把它们包在 handler 外面，每个节点只做一次
+// middleware 是按照注册的路由计算的，和具体的请求路径无关，所以路由要在处理请求之前注册完
+// 只有沿着这个路由一段一段匹配上的节点才会贡献 middleware，旁边的路由上的不会
+func (n *node) resolve(r *router, root *node) {
+	n.resolveOnce.Do(func() {
+		// 中间的节点没有注册路由，也就没有 middleware
+		if n.handler == nil {
+			return
+		}
+		if n.route == "/" {
+			n.routeMdls = root.mdls
+		} else {
+			n.routeMdls = r.findMdls(root, strings.Split(n.route[1:], "/"))
+		}
+		chain := n.handler
+		for i := len(n.routeMdls) - 1; i >= 0; i-- {
+			chain = n.routeMdls[i](chain)
+		}
+		n.chain = chain
+	})
 }
 
 // childOrCreate 查找子节点，
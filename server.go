@@ -1,9 +1,17 @@
 package web
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/fs"
 	"log"
 	"net"
 	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // 确保HttpServer实现Server接口
@@ -16,6 +24,8 @@ type Server interface {
 	http.Handler
 
 	Start(addr string) error
+	// Shutdown 优雅退出，等待正在处理的请求结束
+	Shutdown(ctx context.Context) error
 
 	//添加路由
 	addRoute(method string, path string, handlerFunc HandleFunc, mdls ...Middleware)
@@ -28,9 +38,52 @@ type HttpServer struct {
 	// addr string 创建的时候传递，而不是 Start 接收。这个都是可以的
 	router
 
-	mdls []Middleware
+	// 全局的 middleware，见 middleware_global.go
+	mdls []globalMiddleware
 
-	log func(msg string, args ...any)
+	// 结构化的日志，见 logger.go
+	logger Logger
+
+	uploadLimits UploadLimits
+
+	tplEngine TemplateEngine
+
+	// 组装好的 middleware 链条，见 handler
+	chain atomic.Pointer[HandleFunc]
+
+	// srv 负责连接的管理，inflight 是正在处理的请求数，conns 是 Hijack 之后的连接
+	// 优雅退出的时候需要用到，见 Shutdown
+	srv      *http.Server
+	inflight atomic.Int64
+	conns    connSet
+
+	// 生命周期的回调，见 hook.go
+	beforeStart    []hookEntry
+	afterStart     []func(addr net.Addr) hookEntry
+	beforeShutdown []hookEntry
+	afterShutdown  []hookEntry
+
+	// TLS 的配置，见 tls.go，activeCert 是 StartTLS 实际使用的证书
+	tlsConfig       *tls.Config
+	certProvider    CertProvider
+	selfSignedHosts []string
+	activeCert      atomic.Pointer[activeCert]
+	// mTLS 的配置，见 identity.go
+	clientAuth tls.ClientAuthType
+	clientCAs  *x509.CertPool
+
+	// unix socket 文件的权限，TCP 的 keep-alive
+	socketMode   fs.FileMode
+	tcpKeepAlive time.Duration
+	// Start、StartTLS 创建的 listener，key 是传入的地址，平滑升级的时候传给子进程
+	lnMutex   sync.Mutex
+	listeners map[string]net.Listener
+	// ServerWithListener 额外添加的 listener
+	extraListeners []listenerEntry
+	// 平滑升级的配置，见 upgrade_unix.go
+	upgradeTimeout time.Duration
+	upgradeSignals []os.Signal
+	upgradeOnce    sync.Once
 }
 
 //这种方法也可以，但是缺少拓展性
@@ -46,10 +99,14 @@ type HTTPServerOption func(server *HttpServer)
 func NewHTTPServer(opts ...HTTPServerOption) *HttpServer {
 	res := &HttpServer{
 		router: newRouter(),
-		log: func(msg string, args ...any) {
-			log.Printf(msg, args...)
-		},
+		logger: defaultLogger,
+	}
+	res.srv = &http.Server{
+		Handler:           res,
+		ConnContext:       listenerConnContext,
+		ReadHeaderTimeout: defaultReadHeaderTimeout,
 	}
+	res.srv.ErrorLog = log.New(serverErrorLog{h: res}, "", 0)
 
 	for _, opt := range opts {
 		opt(res)
@@ -57,50 +114,98 @@ func NewHTTPServer(opts ...HTTPServerOption) *HttpServer {
 	return res
 }
 
+// ServerWithMiddleware 会覆盖之前注册的全局 middleware，包括有名字的
+// 需要和别的 option 组合的时候用 ServerWithNamedMiddleware 或者 UseGlobal
 func ServerWithMiddleware(mdls ...Middleware) HTTPServerOption {
 	return func(server *HttpServer) {
-		server.mdls = mdls //直接覆盖
+		server.mdls = nil //直接覆盖
+		server.UseGlobal(mdls...)
 	}
 }
 
 // http.Handler接口中的方法  所有请求都经过这里
 func (h *HttpServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	// 你的框架代码就在这里
-	ctx := &Context{
-		Req:  request,
-		Resp: writer,
+	// Context 本身就是 context.Context，会被传给 DB、RPC 之类的调用，甚至被异步的 goroutine 持有
+	// 所以每个请求都创建新的，不能复用
+	h.serveContext(&Context{}, writer, request)
+}
+
+// ServeContext 和 ServeHTTP 一样处理请求，处理完之后把 Context 返回给调用者
+// 主要给 webtest 这种不监听端口的测试使用，可以检查 MatchedRoute、PathParams 之类的结果
+func (h *HttpServer) ServeContext(writer http.ResponseWriter, request *http.Request) *Context {
+	ctx := &Context{}
+	h.serveContext(ctx, writer, request)
+	return ctx
+}
+
+func (h *HttpServer) serveContext(ctx *Context, writer http.ResponseWriter, request *http.Request) {
+	h.inflight.Add(1)
+	defer h.inflight.Add(-1)
+	ctx.reset(writer, request)
+	ctx.uploadLimits = &h.uploadLimits
+	ctx.tplEngine = h.tplEngine
+	ctx.rw.conns = &h.conns
+	ctx.logger = h.logger
+	// 用 defer 清理，handler panic 的时候临时文件和 Hijack 的连接也不会泄露
+	defer h.cleanup(ctx)
+
+	//这里执行的时候，就是从前往后了
+	h.handler()(ctx)
+
+	// 因为它在调用 middleware 之后才回写响应，
+	// 所以实际上 flashResp 是最后一个步骤
+	h.flashResp(ctx)
+}
+
+// cleanup 释放请求占用的资源，不管 handler 是正常返回还是 panic 都会执行
+func (h *HttpServer) cleanup(ctx *Context) {
+	// 清理上传时写到临时目录的文件
+	if err := ctx.RemoveUploadedFiles(); err != nil {
+		ctx.Logger().Warn("清理上传的临时文件失败", "error", err)
 	}
+	// handler 返回之后，Hijack 的连接就不再归我们管了
+	if ctx.rw.conn != nil {
+		h.conns.remove(ctx.rw.conn)
+	}
+}
 
-	//h.server(ctx)
+// handler 返回组装好的 middleware 链条，只在第一次请求或者 Start 的时候组装一次
+func (h *HttpServer) handler() HandleFunc {
+	if root := h.chain.Load(); root != nil {
+		return *root
+	}
+	return h.buildChain()
+}
 
+// buildChain 重新组装 middleware 链条，并发的请求最多重复组装几次，结果是一样的
+func (h *HttpServer) buildChain() HandleFunc {
 	// 最后一个是这个
-	root := h.server
+	var root HandleFunc = h.server
 	//从后往前  设置调用逻辑，把后一个的返回值参数，作为前一个next 组装链条
 	for i := len(h.mdls) - 1; i >= 0; i-- {
-		root = h.mdls[i](root)
-	}
-
-	// 第一个应该是回写响应的
-	// 因为它在调用next之后才回写响应，
-	// 所以实际上 flashResp 是最后一个步骤
-	var m Middleware = func(next HandleFunc) HandleFunc {
-		return func(ctx *Context) {
-			next(ctx)
-			h.flashResp(ctx)
-		}
+		root = h.mdls[i].mdl(root)
 	}
-	root = m(root)
-	//这里执行的时候，就是从前往后了
-	root(ctx)
+	h.chain.Store(&root)
+	return root
 }
 
 func (h *HttpServer) flashResp(ctx *Context) {
-	if ctx.RespStatusCode != 0 {
+	ctx.respFlushed = true
+	// 升级成 WebSocket 之类的，连接已经不归 http 管了
+	if ctx.resp.hijacked {
+		return
+	}
+	if ctx.RespStatusCode != 0 && !ctx.resp.wroteHeader {
 		ctx.Resp.WriteHeader(ctx.RespStatusCode)
 	}
+	// 没有数据就不写，像 Static 这种直接写 Resp 的，可能已经返回了不允许有 body 的 304
+	if len(ctx.RespData) == 0 {
+		return
+	}
 	datalen, err := ctx.Resp.Write(ctx.RespData)
 	if err != nil || datalen != len(ctx.RespData) {
-		h.log("回写相应失败: %v", err)
+		ctx.Logger().Error("回写响应失败", "error", err)
 	}
 }
 
@@ -118,36 +223,98 @@ func (h *HttpServer) server(ctx *Context) {
 	}
 	ctx.PathParams = mi.pathParams
 	ctx.MatchedRoute = mi.n.route
+	// 路由上注册的 middleware 已经包在 handler 外面了，在全局的 middleware 里面执行
 	//before exec
-	mi.n.handler(ctx)
+	mi.n.chain(ctx)
 	//after exec
+	// 用户直接写了 Resp，以实际写出去的状态码为准，这样 middleware 拿到的才是真实的结果
+	if ctx.resp != nil && ctx.resp.wroteHeader {
+		ctx.RespStatusCode = ctx.resp.status
+	}
 
 }
 
 // Start 启动服务器时，用户传入指定端口
 // 这种就是编程接口
+// 除了 TCP 地址，还支持 unix:// 和 systemd://，见 listen
 func (h *HttpServer) Start(addr string) error {
 	if addr == "" {
 		addr = ":http"
 	}
-	ln, err := net.Listen("tcp", addr)
+	lns, err := h.listenAll(addr, nil)
 	if err != nil {
 		return err
 	}
+	return h.serve(lns...)
+}
+
+// serve 会一直阻塞，直到 Shutdown 之后返回 http.ErrServerClosed
+// OnBeforeStart、OnAfterStart 失败的时候返回它们的 error
+// 有多个 listener 的时候，任何一个出错都会关闭所有的 listener，返回这个 error
+func (h *HttpServer) serve(lns ...net.Listener) error {
+	h.buildChain()
+	if err := runHooks(context.Background(), "OnBeforeStart", h.beforeStart); err != nil {
+		for _, ln := range lns {
+			_ = ln.Close()
+		}
+		h.logger.Error("服务器启动失败", "error", err)
+		return err
+	}
+	// 平滑升级出来的子进程，通知父进程可以退出了
+	notifyUpgradeReady()
+	if len(h.upgradeSignals) > 0 {
+		h.upgradeOnce.Do(h.watchUpgrade)
+	}
+
+	served := make(chan error, len(lns))
+	for _, ln := range lns {
+		go func(ln net.Listener) {
+			served <- h.srv.Serve(ln)
+		}(ln)
+		h.logger.Info("服务器启动", "network", ln.Addr().Network(), "addr", ln.Addr().String())
+	}
+	if len(h.afterStart) > 0 {
+		// 多个 listener 的时候传入的是第一个的地址
+		hooks := make([]hookEntry, 0, len(h.afterStart))
+		for _, hook := range h.afterStart {
+			hooks = append(hooks, hook(lns[0].Addr()))
+		}
+		if err := runHooks(context.Background(), "OnAfterStart", hooks); err != nil {
+			_ = h.srv.Close()
+			for range lns {
+				<-served
+			}
+			h.logger.Error("服务器启动失败", "error", err)
+			return err
+		}
+	}
 
-	// 在这里，可以让用户注册所谓的 after start 回调
-	// 比如说往你的 admin 注册一下自己这个实例
-	// 在这里执行一些你业务所需的前置条件
+	var res error
+	for i := range lns {
+		err := <-served
+		if i == 0 {
+			res = err
+			if err != http.ErrServerClosed {
+				_ = h.srv.Close()
+			}
+		}
+	}
+	return res
+}
 
-	return http.Serve(ln, h)
+// addRoute 注册路由，并且在 Debug 级别记录下来
+func (h *HttpServer) addRoute(method string, path string, handler HandleFunc, mdls ...Middleware) {
+	h.router.addRoute(method, path, handler, mdls...)
+	h.logger.Debug("注册路由", "method", method, "path", path, "middlewares", len(mdls))
 }
 
-func (h *HttpServer) Get(path string, handler HandleFunc) {
-	h.addRoute(http.MethodGet, path, handler)
+// Get、POST 的 mdls 只作用在这个路由以及它下面的路由上
+func (h *HttpServer) Get(path string, handler HandleFunc, mdls ...Middleware) {
+	h.addRoute(http.MethodGet, path, handler, mdls...)
 }
 
-func (h *HttpServer) POST(path string, handler HandleFunc) {
-	h.addRoute(http.MethodPost, path, handler)
+func (h *HttpServer) POST(path string, handler HandleFunc, mdls ...Middleware) {
+	h.addRoute(http.MethodPost, path, handler, mdls...)
 }
 
 
@@ -0,0 +1,90 @@
+package web
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 下面这些选项都是直接设置内部的 http.Server
+// 默认只设置了 ReadHeaderTimeout，防止 slowloris 这种慢慢发送请求头的攻击
+// ReadTimeout、WriteTimeout 会把 Stream、SSE、WebSocket 这种长时间的请求断开，需要根据业务自己设置
+
+const defaultReadHeaderTimeout = 10 * time.Second
+
+// ServerWithReadHeaderTimeout 读取请求头的超时时间，默认 10 秒
+func ServerWithReadHeaderTimeout(timeout time.Duration) HTTPServerOption {
+	return func(server *HttpServer) {
+		server.srv.ReadHeaderTimeout = timeout
+	}
+}
+
+// ServerWithReadTimeout 读取整个请求的超时时间，包括请求体
+func ServerWithReadTimeout(timeout time.Duration) HTTPServerOption {
+	return func(server *HttpServer) {
+		server.srv.ReadTimeout = timeout
+	}
+}
+
+// ServerWithWriteTimeout 从读完请求头开始，到写完响应的超时时间
+func ServerWithWriteTimeout(timeout time.Duration) HTTPServerOption {
+	return func(server *HttpServer) {
+		server.srv.WriteTimeout = timeout
+	}
+}
+
+// ServerWithIdleTimeout keep-alive 的连接空闲多久之后关闭
+func ServerWithIdleTimeout(timeout time.Duration) HTTPServerOption {
+	return func(server *HttpServer) {
+		server.srv.IdleTimeout = timeout
+	}
+}
+
+// ServerWithMaxHeaderBytes 请求头的最大字节数，默认是 http.DefaultMaxHeaderBytes，也就是 1MB
+func ServerWithMaxHeaderBytes(n int) HTTPServerOption {
+	return func(server *HttpServer) {
+		server.srv.MaxHeaderBytes = n
+	}
+}
+
+// ServerWithKeepAlivesEnabled 是否开启 HTTP 的 keep-alive，默认开启
+func ServerWithKeepAlivesEnabled(enabled bool) HTTPServerOption {
+	return func(server *HttpServer) {
+		server.srv.SetKeepAlivesEnabled(enabled)
+	}
+}
+
+// ServerWithTCPKeepAlive 设置 TCP 层面的 keep-alive 探测间隔，小于 0 表示关闭
+// 只对 Start、StartTLS 监听的 TCP 地址生效
+func ServerWithTCPKeepAlive(period time.Duration) HTTPServerOption {
+	return func(server *HttpServer) {
+		server.tcpKeepAlive = period
+	}
+}
+
+// ServerWithConnState 连接状态变化的时候回调，可以用来统计连接数
+func ServerWithConnState(fn func(conn net.Conn, state http.ConnState)) HTTPServerOption {
+	return func(server *HttpServer) {
+		server.srv.ConnState = fn
+	}
+}
+
+// ServerWithErrorLog 设置 http.Server 自己的错误日志，例如 TLS 握手失败
+// 默认输出到 HttpServer 的日志里面
+func ServerWithErrorLog(logger *log.Logger) HTTPServerOption {
+	return func(server *HttpServer) {
+		server.srv.ErrorLog = logger
+	}
+}
+
+// serverErrorLog 把 http.Server 的日志转给 HttpServer 的 Logger
+type serverErrorLog struct {
+	h *HttpServer
+}
+
+func (s serverErrorLog) Write(p []byte) (int, error) {
+	s.h.logger.Error(strings.TrimSuffix(string(p), "\n"), "source", "net/http")
+	return len(p), nil
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerOptions(t *testing.T) {
+	var mutex sync.Mutex
+	var states []http.ConnState
+	logs := &lockedBuffer{}
+	server := NewHTTPServer(
+		ServerWithLogger(NewJSONLogger(logs, LevelInfo)),
+		ServerWithReadHeaderTimeout(100*time.Millisecond),
+		ServerWithIdleTimeout(time.Minute),
+		ServerWithMaxHeaderBytes(4096),
+		ServerWithConnState(func(conn net.Conn, state http.ConnState) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			states = append(states, state)
+		}))
+	assert.Equal(t, 100*time.Millisecond, server.srv.ReadHeaderTimeout)
+	assert.Equal(t, time.Minute, server.srv.IdleTimeout)
+	assert.Equal(t, 4096, server.srv.MaxHeaderBytes)
+	// 默认的 ReadHeaderTimeout
+	assert.Equal(t, defaultReadHeaderTimeout, NewHTTPServer().srv.ReadHeaderTimeout)
+	server.Get("/panic", func(ctx *Context) {
+		panic("boom")
+	})
+
+	url, _ := startTestServer(t, server)
+	defer server.srv.Close()
+
+	// 请求头太大
+	req, err := http.NewRequest(http.MethodGet, url+"/", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Large", strings.Repeat("a", 8192))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, resp.StatusCode)
+
+	// 迟迟不发完请求头，超时之后连接被关闭
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\n"))
+	require.NoError(t, err)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = conn.Read(make([]byte, 1024))
+	assert.Error(t, err)
+
+	// http.Server 自己的错误日志写到 HttpServer 的日志里面
+	_, err = http.Get(url + "/panic")
+	assert.Error(t, err)
+	require.Eventually(t, func() bool {
+		return strings.Contains(logs.String(), "http: panic serving")
+	}, time.Second, 10*time.Millisecond)
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Contains(t, states, http.StateNew)
+	assert.Contains(t, states, http.StateClosed)
+
+	buf := &bytes.Buffer{}
+	logger := log.New(buf, "", 0)
+	assert.Equal(t, logger, NewHTTPServer(ServerWithErrorLog(logger)).srv.ErrorLog)
+}
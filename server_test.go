@@ -1,14 +1,22 @@
 package web
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHttpServer_ServeHTTP(t *testing.T) {
 	server := NewHTTPServer()
-	server.mdls = []Middleware{
+	server.UseGlobal(
 		func(next HandleFunc) HandleFunc {
 			return func(ctx *Context) {
 				fmt.Println("第一个before")
@@ -33,6 +41,174 @@ func TestHttpServer_ServeHTTP(t *testing.T) {
 				fmt.Println("第四个，你看不到这句话")
 			}
 		},
-	}
+	)
 	server.ServeHTTP(nil, &http.Request{})
-}
\ No newline at end of file
+}
+func TestHttpServer_ServeHTTP_FreshContext(t *testing.T) {
+	builds := 0
+	server := NewHTTPServer(ServerWithMiddleware(func(next HandleFunc) HandleFunc {
+		builds++
+		return next
+	}))
+	var kept map[string]string
+	server.Get("/user/:id", func(ctx *Context) {
+		// 每个请求都是新的 Context，上一个请求的数据不会残留下来
+		assert.Nil(t, ctx.Value("user"))
+		assert.Empty(t, ctx.RespData)
+		ctx.SetValue("user", "tom")
+		if kept == nil {
+			kept = ctx.PathParams
+		}
+		ctx.RespData = []byte(ctx.PathParams["id"])
+	})
+
+	for _, id := range []string{"1", "2", "3"} {
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/user/"+id, nil))
+		assert.Equal(t, id, resp.Body.String())
+	}
+	// middleware 只组装一次
+	assert.Equal(t, 1, builds)
+	// 保存下来的 PathParams 不会被后面的请求修改
+	assert.Equal(t, map[string]string{"id": "1"}, kept)
+}
+
+func TestHttpServer_ServeHTTP_ContextOutlivesHandler(t *testing.T) {
+	server := NewHTTPServer()
+	kept := map[string]context.Context{}
+	server.Get("/user/:id", func(ctx *Context) {
+		ctx.SetValue("user", "user-"+ctx.PathParams["id"])
+		// 派生出来的 context 在 handler 返回之后还会被使用，例如异步的 RPC 调用
+		kept[ctx.PathParams["id"]] = context.WithValue(ctx, "trace", ctx.PathParams["id"])
+	})
+	for _, id := range []string{"a", "b"} {
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user/"+id, nil))
+	}
+	assert.Equal(t, "user-a", kept["a"].Value("user"))
+	assert.Equal(t, "user-b", kept["b"].Value("user"))
+}
+
+func TestHttpServer_RouteMiddleware(t *testing.T) {
+	builds := 0
+	server := NewHTTPServer()
+	server.Get("/user/:id", func(ctx *Context) {
+		ctx.RespData = append(ctx.RespData, ctx.PathParams["id"]...)
+	}, func(next HandleFunc) HandleFunc {
+		builds++
+		return func(ctx *Context) {
+			ctx.RespData = []byte("mdl ")
+			next(ctx)
+		}
+	})
+
+	for _, id := range []string{"1", "2", "3"} {
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/user/"+id, nil))
+		assert.Equal(t, "mdl "+id, resp.Body.String())
+	}
+	// 路由上的 middleware 也只组装一次
+	assert.Equal(t, 1, builds)
+
+	// 路由上的 middleware 只能作用在匹配上的路由上，不能影响旁边的路由
+	// 例如 mTLS 的策略挂在 /admin/:id 上，/public/1 不能因此被拒绝
+	mdl := func(name string) Middleware {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				ctx.Resp.Header().Add("X-Mdl", name)
+				next(ctx)
+			}
+		}
+	}
+	ok := func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusOK
+	}
+	server = NewHTTPServer()
+	server.Get("/admin/:id", ok, mdl("admin"))
+	server.Get("/public/:id", ok)
+	server.Get("/public/admin", ok)
+	server.Get("/order/:id(^[0-9]+$)", ok, mdl("order"))
+	server.Get("/order/detail", ok)
+
+	testCases := []struct {
+		name     string
+		path     string
+		wantMdls []string
+	}{
+		{
+			name:     "admin",
+			path:     "/admin/1",
+			wantMdls: []string{"admin"},
+		},
+		{
+			name: "sibling param",
+			path: "/public/1",
+		},
+		{
+			name: "sibling static",
+			path: "/public/admin",
+		},
+		{
+			// 正则路由上的 middleware 一样要生效
+			name:     "regex",
+			path:     "/order/123",
+			wantMdls: []string{"order"},
+		},
+		{
+			name: "regex sibling",
+			path: "/order/detail",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, tc.wantMdls, resp.Header().Values("X-Mdl"))
+		})
+	}
+}
+
+// hijackRecorder 是支持 Hijack 的 ResponseRecorder
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	conn net.Conn
+}
+
+func (r *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return r.conn, nil, nil
+}
+
+func TestHttpServer_ServeHTTP_PanicCleanup(t *testing.T) {
+	tmpDir := t.TempDir()
+	server := NewHTTPServer(ServerWithUploadLimits(UploadLimits{TempDir: tmpDir}))
+	server.POST("/upload", func(ctx *Context) {
+		_, err := ctx.MultipartForm(16)
+		require.NoError(t, err)
+		panic("upload")
+	})
+	server.Get("/chat", func(ctx *Context) {
+		_, _, err := ctx.Resp.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		panic("chat")
+	})
+
+	// handler panic 的时候，写到临时目录的文件也要清理掉
+	req := newMultipartRequest(t, nil, map[string][]byte{"avatar": make([]byte, 1024)})
+	assert.Panics(t, func() {
+		server.ServeHTTP(httptest.NewRecorder(), req)
+	})
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// Hijack 的连接也不能留在 connSet 里面
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+	assert.Panics(t, func() {
+		server.ServeHTTP(&hijackRecorder{ResponseRecorder: httptest.NewRecorder(), conn: conn},
+			httptest.NewRequest(http.MethodGet, "/chat", nil))
+	})
+	assert.Empty(t, server.conns.conns)
+	assert.Equal(t, int64(0), server.inflight.Load())
+}
@@ -0,0 +1,118 @@
+package web
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Shutdown 优雅退出
+// 1. 不再接收新的连接，关闭空闲的连接
+// 2. 等待正在处理的请求结束，包括 Hijack 之后的 WebSocket、SSE 这种长时间运行的请求
+// 3. ctx 到期了还没有结束的，强制关闭连接，返回 ctx.Err()
+// Shutdown 之后 Start 会返回 http.ErrServerClosed
+// 前后分别执行 OnBeforeShutdown、OnAfterShutdown，所有的 error 合并之后返回
+func (h *HttpServer) Shutdown(ctx context.Context) error {
+	h.logger.Info("开始优雅退出", "inflight", h.inflight.Load())
+	errs := appendErr(nil, runHooks(ctx, "OnBeforeShutdown", h.beforeShutdown))
+	errs = appendErr(errs, h.shutdown(ctx))
+	// 即便 ctx 已经超时了，缓冲的数据也要刷出去，所以只受 hook 自己的超时时间限制
+	errs = appendErr(errs, runHooks(context.Background(), "OnAfterShutdown", h.afterShutdown))
+	if err := errs.ErrorOrNil(); err != nil {
+		h.logger.Error("优雅退出失败", "error", err)
+		return err
+	}
+	h.logger.Info("优雅退出完成")
+	return nil
+}
+
+func (h *HttpServer) shutdown(ctx context.Context) error {
+	err := h.srv.Shutdown(ctx)
+	// http.Server 不管 Hijack 之后的连接，所以还要等 ServeHTTP 全部返回
+	if err == nil {
+		err = h.waitInflight(ctx)
+	}
+	if err != nil {
+		// 超时了，关闭所有的连接，Stream、SSE 之类的请求会因为 ctx.Done 结束
+		_ = h.srv.Close()
+		h.conns.closeAll()
+	}
+	return err
+}
+
+// ShutdownOnSignal 收到 signals 的时候调用 Shutdown，timeout 是留给正在处理的请求的时间
+// 不传 signals 的时候默认是 SIGINT 和 SIGTERM
+// 返回的 channel 会收到 Shutdown 的结果，一般的用法是：
+//
+//	done := server.ShutdownOnSignal(30 * time.Second)
+//	if err := server.Start(":8080"); err != http.ErrServerClosed {
+//		log.Fatal(err)
+//	}
+//	if err := <-done; err != nil {
+//		log.Println(err)
+//	}
+func (h *HttpServer) ShutdownOnSignal(timeout time.Duration, signals ...os.Signal) <-chan error {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	done := make(chan error, 1)
+	go func() {
+		<-ch
+		// 再收到一次信号就按照默认的行为直接退出
+		signal.Stop(ch)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		done <- h.Shutdown(ctx)
+	}()
+	return done
+}
+
+func (h *HttpServer) waitInflight(ctx context.Context) error {
+	// 和 http.Server.Shutdown 一样轮询
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for h.inflight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// connSet 记录 Hijack 之后还在使用的连接
+type connSet struct {
+	mutex sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func (s *connSet) add(conn net.Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]struct{})
+	}
+	s.conns[conn] = struct{}{}
+}
+
+func (s *connSet) remove(conn net.Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.conns, conn)
+}
+
+func (s *connSet) closeAll() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for conn := range s.conns {
+		_ = conn.Close()
+		delete(s.conns, conn)
+	}
+}
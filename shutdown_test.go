@@ -0,0 +1,96 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startTestServer(t *testing.T, server *HttpServer) (string, <-chan error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() {
+		served <- server.serve(ln)
+	}()
+	return "http://" + ln.Addr().String(), served
+}
+
+func TestHttpServer_Shutdown(t *testing.T) {
+	started := make(chan struct{})
+	server := NewHTTPServer()
+	server.Get("/slow", func(ctx *Context) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("done")
+	})
+	url, served := startTestServer(t, server)
+
+	type result struct {
+		body string
+		err  error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err != nil {
+			resCh <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		resCh <- result{body: string(body), err: err}
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, server.Shutdown(ctx))
+	// 正在处理的请求正常结束
+	res := <-resCh
+	require.NoError(t, res.err)
+	assert.Equal(t, "done", res.body)
+	assert.Equal(t, http.ErrServerClosed, <-served)
+
+	// 不再接收新的请求
+	_, err := http.Get(url + "/slow")
+	assert.Error(t, err)
+}
+
+func TestHttpServer_Shutdown_Hijacked(t *testing.T) {
+	upgraded := make(chan struct{})
+	server := NewHTTPServer()
+	server.Get("/chat", func(ctx *Context) {
+		conn, err := ctx.Upgrade()
+		if err != nil {
+			return
+		}
+		close(upgraded)
+		// 一直阻塞，直到连接被关闭
+		_, _, _ = conn.ReadMessage()
+	})
+	url, served := startTestServer(t, server)
+
+	conn, br, _ := dialWebSocket(t, url)
+	defer conn.Close()
+	<-upgraded
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	// Hijack 的连接也要等，超时之后强制关闭
+	assert.Equal(t, context.DeadlineExceeded, server.Shutdown(ctx))
+	assert.Equal(t, http.ErrServerClosed, <-served)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err := br.ReadByte()
+	assert.Equal(t, io.EOF, err)
+	assert.Eventually(t, func() bool {
+		return server.inflight.Load() == 0
+	}, time.Second, 10*time.Millisecond)
+}
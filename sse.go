@@ -0,0 +1,277 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errSSELineBreak event 和 id 里面有换行的话，客户端会把后面的内容当成新的字段
+var errSSELineBreak = errors.New("web: SSE 的 event 和 id 不能包含换行")
+
+// sseLineBreaks 和 EventSource 一样，\r\n、\r、\n 都算换行
+var sseLineBreaks = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// SSEEvent 是一条 Server-Sent Event
+// Data 是 string 或者 []byte 的时候原样输出，其余的类型编码成 JSON
+type SSEEvent struct {
+	Event string
+	ID    string
+	Data  any
+}
+
+// SSEWriter 按照 text/event-stream 的格式写事件，每写一条都会 Flush
+// 和 Stream 一样绕开了 RespData，不是并发安全的
+type SSEWriter struct {
+	ctx *Context
+}
+
+// SSE 写入 text/event-stream 的响应头，返回事件的写入器
+func (c *Context) SSE() *SSEWriter {
+	header := c.Resp.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// 避免 nginx 之类的代理缓冲
+	header.Set("X-Accel-Buffering", "no")
+	c.Resp.WriteHeader(http.StatusOK)
+	s := &SSEWriter{ctx: c}
+	s.flush()
+	return s
+}
+
+// LastEventID 是客户端断线重连时带上来的最后一个事件 ID
+func (s *SSEWriter) LastEventID() string {
+	return s.ctx.Req.Header.Get("Last-Event-ID")
+}
+
+// Send 发送一条事件，event 和 id 可以为空，但是不能包含换行
+func (s *SSEWriter) Send(event string, id string, data any) error {
+	if strings.ContainsAny(event, "\r\n") || strings.ContainsAny(id, "\r\n") {
+		return errSSELineBreak
+	}
+	var sb strings.Builder
+	if id != "" {
+		sb.WriteString("id: ")
+		sb.WriteString(id)
+		sb.WriteByte('\n')
+	}
+	if event != "" {
+		sb.WriteString("event: ")
+		sb.WriteString(event)
+		sb.WriteByte('\n')
+	}
+	payload, err := ssePayload(data)
+	if err != nil {
+		return err
+	}
+	// 多行数据每一行都需要 data: 前缀，否则数据里面的换行可以伪造 event、id、retry 字段
+	for _, line := range strings.Split(sseLineBreaks.Replace(payload), "\n") {
+		sb.WriteString("data: ")
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	sb.WriteByte('\n')
+	return s.write(sb.String())
+}
+
+// SendEvent 等价于 Send(evt.Event, evt.ID, evt.Data)
+func (s *SSEWriter) SendEvent(evt SSEEvent) error {
+	return s.Send(evt.Event, evt.ID, evt.Data)
+}
+
+// Retry 告诉客户端断线之后多久重连
+func (s *SSEWriter) Retry(d time.Duration) error {
+	return s.write("retry: " + strconv.FormatInt(d.Milliseconds(), 10) + "\n\n")
+}
+
+// Heartbeat 发送一个注释行，防止连接因为空闲被代理断开
+func (s *SSEWriter) Heartbeat() error {
+	return s.write(": ping\n\n")
+}
+
+func (s *SSEWriter) write(data string) error {
+	if _, err := io.WriteString(s.ctx.Resp, data); err != nil {
+		s.ctx.RespErr = err
+		return err
+	}
+	s.flush()
+	return nil
+}
+
+func (s *SSEWriter) flush() {
+	if f, ok := s.ctx.Resp.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func ssePayload(data any) (string, error) {
+	switch d := data.(type) {
+	case string:
+		return d, nil
+	case []byte:
+		return string(d), nil
+	}
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+type BrokerOption func(b *Broker)
+
+// BrokerWithHistory 每个 topic 保留最近的 n 条事件
+// 客户端带着 Last-Event-ID 重连时，会补发这个 ID 之后的事件
+func BrokerWithHistory(n int) BrokerOption {
+	return func(b *Broker) {
+		b.historySize = n
+	}
+}
+
+// BrokerWithBufferSize 设置每个订阅者的缓冲区大小，缓冲区满了的时候新的事件会被丢弃
+func BrokerWithBufferSize(n int) BrokerOption {
+	return func(b *Broker) {
+		b.bufferSize = n
+	}
+}
+
+// Broker 是进程内的发布订阅，把事件扇出给所有订阅了同一个 topic 的客户端
+type Broker struct {
+	mutex       sync.RWMutex
+	subscribers map[string]map[chan SSEEvent]struct{}
+	history     map[string][]SSEEvent
+
+	bufferSize  int
+	historySize int
+}
+
+func NewBroker(opts ...BrokerOption) *Broker {
+	res := &Broker{
+		subscribers: make(map[string]map[chan SSEEvent]struct{}),
+		history:     make(map[string][]SSEEvent),
+		bufferSize:  16,
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+// Publish 把事件发给 topic 的所有订阅者，不会因为某一个慢的订阅者阻塞
+func (b *Broker) Publish(topic string, evt SSEEvent) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.historySize > 0 {
+		h := append(b.history[topic], evt)
+		if len(h) > b.historySize {
+			h = h[len(h)-b.historySize:]
+		}
+		b.history[topic] = h
+	}
+	for ch := range b.subscribers[topic] {
+		select {
+		case ch <- evt:
+		default:
+		}
+	}
+}
+
+// Subscribe 订阅 topic，ctx 结束的时候自动取消订阅并关闭返回的 channel
+// 直接传入 *Context 就可以在客户端断开的时候清理
+func (b *Broker) Subscribe(ctx context.Context, topic string) <-chan SSEEvent {
+	ch, _ := b.subscribe(ctx, topic, "")
+	return ch
+}
+
+// subscribe 在同一把锁里面订阅并且拿到 lastID 之后的历史事件
+// 这样历史事件和订阅之间发布的事件既不会丢，也不会重复
+func (b *Broker) subscribe(ctx context.Context, topic string, lastID string) (<-chan SSEEvent, []SSEEvent) {
+	ch := make(chan SSEEvent, b.bufferSize)
+	b.mutex.Lock()
+	var missed []SSEEvent
+	if lastID != "" {
+		missed = b.eventsAfter(topic, lastID)
+	}
+	subs, ok := b.subscribers[topic]
+	if !ok {
+		subs = make(map[chan SSEEvent]struct{})
+		b.subscribers[topic] = subs
+	}
+	subs[ch] = struct{}{}
+	b.mutex.Unlock()
+
+	// 提前拿到 Done，后台的 goroutine 不再访问 ctx
+	done := ctx.Done()
+	go func() {
+		<-done
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		delete(subs, ch)
+		if len(b.subscribers[topic]) == 0 {
+			delete(b.subscribers, topic)
+		}
+		close(ch)
+	}()
+	return ch, missed
+}
+
+// Serve 把 topic 的事件推送给当前的客户端，直到客户端断开
+// heartbeat 大于 0 的时候按照这个间隔发送心跳
+func (b *Broker) Serve(ctx *Context, topic string, heartbeat time.Duration) error {
+	sse := ctx.SSE()
+	events, missed := b.subscribe(ctx, topic, sse.LastEventID())
+	for _, evt := range missed {
+		if err := sse.SendEvent(evt); err != nil {
+			return err
+		}
+	}
+
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case evt, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := sse.SendEvent(evt); err != nil {
+				return err
+			}
+		case <-tick:
+			if err := sse.Heartbeat(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Subscribers 返回 topic 当前的订阅者数量
+func (b *Broker) Subscribers(topic string) int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return len(b.subscribers[topic])
+}
+
+// eventsAfter 需要持有锁
+func (b *Broker) eventsAfter(topic string, id string) []SSEEvent {
+	h := b.history[topic]
+	for i := len(h) - 1; i >= 0; i-- {
+		if h[i].ID == id {
+			return append([]SSEEvent(nil), h[i+1:]...)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSEWriter(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Last-Event-ID", "7")
+	resp := httptest.NewRecorder()
+	ctx := &Context{Req: req, Resp: resp}
+
+	sse := ctx.SSE()
+	assert.Equal(t, "7", sse.LastEventID())
+	require.NoError(t, sse.Retry(3*time.Second))
+	require.NoError(t, sse.Send("message", "8", "line1\nline2"))
+	require.NoError(t, sse.Send("", "", map[string]string{"name": "Tom"}))
+	require.NoError(t, sse.Heartbeat())
+	// 单独的 \r 也是换行，不能用来伪造字段
+	require.NoError(t, sse.Send("", "", "a\rid: 9\r\nb"))
+	assert.Equal(t, errSSELineBreak, sse.Send("message\rretry: 1", "", "x"))
+	assert.Equal(t, errSSELineBreak, sse.Send("", "9\n", "x"))
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "text/event-stream", resp.Header().Get("Content-Type"))
+	assert.Equal(t, "retry: 3000\n\n"+
+		"id: 8\nevent: message\ndata: line1\ndata: line2\n\n"+
+		"data: {\"name\":\"Tom\"}\n\n"+
+		": ping\n\n"+
+		"data: a\ndata: id: 9\ndata: b\n\n", resp.Body.String())
+}
+
+func TestBroker(t *testing.T) {
+	broker := NewBroker(BrokerWithHistory(10))
+	broker.Publish("dashboard", SSEEvent{ID: "1", Data: "old"})
+	broker.Publish("dashboard", SSEEvent{ID: "2", Data: "missed"})
+
+	server := NewHTTPServer()
+	server.Get("/events", func(ctx *Context) {
+		_ = broker.Serve(ctx, "dashboard", time.Hour)
+	})
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	reqCtx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, ts.URL+"/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+
+	readEvent := func() string {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			if line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	// 补发 Last-Event-ID 之后的事件
+	assert.Equal(t, "id: 2\ndata: missed\n", readEvent())
+
+	require.Eventually(t, func() bool {
+		return broker.Subscribers("dashboard") == 1
+	}, time.Second, 10*time.Millisecond)
+	broker.Publish("dashboard", SSEEvent{Event: "update", ID: "3", Data: "new"})
+	assert.Equal(t, "id: 3\nevent: update\ndata: new\n", readEvent())
+
+	// 客户端断开之后要取消订阅
+	cancel()
+	require.Eventually(t, func() bool {
+		return broker.Subscribers("dashboard") == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestBroker_subscribe(t *testing.T) {
+	broker := NewBroker(BrokerWithHistory(10))
+	broker.Publish("dashboard", SSEEvent{ID: "1", Data: "old"})
+	broker.Publish("dashboard", SSEEvent{ID: "2", Data: "missed"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, missed := broker.subscribe(ctx, "dashboard", "1")
+	assert.Equal(t, []SSEEvent{{ID: "2", Data: "missed"}}, missed)
+
+	// 订阅之后发布的事件只会从 channel 收到一次
+	broker.Publish("dashboard", SSEEvent{ID: "3", Data: "new"})
+	assert.Equal(t, SSEEvent{ID: "3", Data: "new"}, <-events)
+	select {
+	case evt := <-events:
+		t.Fatalf("重复收到事件 %v", evt)
+	default:
+	}
+}
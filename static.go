@@ -0,0 +1,165 @@
+package web
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type StaticOption func(s *staticHandler)
+
+// StaticWithGzip 客户端支持 gzip 的时候，优先返回同目录下预先压缩好的 .gz 文件
+// 例如请求 app.js，存在 app.js.gz 就返回它
+func StaticWithGzip() StaticOption {
+	return func(s *staticHandler) {
+		s.gzip = true
+	}
+}
+
+// StaticWithIndex 设置请求目录时返回的文件，默认是 index.html
+func StaticWithIndex(index string) StaticOption {
+	return func(s *staticHandler) {
+		s.index = index
+	}
+}
+
+// Static 把 prefix 下面的请求映射到本地目录 root
+// 例如 Static("/assets", "./public")，那么 /assets/js/app.js 对应 ./public/js/app.js
+func (h *HttpServer) Static(prefix string, root string, opts ...StaticOption) {
+	h.StaticFS(prefix, os.DirFS(root), opts...)
+}
+
+// StaticFS 和 Static 一样，只不过文件来自 fs.FS，例如 embed.FS
+// 支持 Range、ETag、Last-Modified 以及对应的条件请求，文件内容直接写到 Resp，不经过 RespData
+func (h *HttpServer) StaticFS(prefix string, fsys fs.FS, opts ...StaticOption) {
+	prefix = strings.TrimRight(prefix, "/")
+	s := &staticHandler{
+		prefix: prefix,
+		fs:     fsys,
+		index:  "index.html",
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		if prefix != "" {
+			h.addRoute(method, prefix, s.serve)
+		}
+		h.addRoute(method, prefix+"/*", s.serve)
+	}
+}
+
+type staticHandler struct {
+	prefix string
+	fs     fs.FS
+	index  string
+	gzip   bool
+
+	// embed.FS 的文件没有修改时间，只能用内容计算 ETag，算一次就缓存起来
+	etags sync.Map
+}
+
+func (s *staticHandler) serve(ctx *Context) {
+	name := strings.TrimPrefix(ctx.Req.URL.Path, s.prefix)
+	// path.Clean 会去掉 ..，保证不会访问到 root 外面的文件
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+
+	f, stat, err := s.open(name)
+	if err != nil {
+		ctx.RespStatusCode = http.StatusNotFound
+		ctx.RespData = []byte("Not Found")
+		return
+	}
+	if stat.IsDir() {
+		_ = f.Close()
+		name = path.Join(name, s.index)
+		if f, stat, err = s.open(name); err != nil || stat.IsDir() {
+			ctx.RespStatusCode = http.StatusNotFound
+			ctx.RespData = []byte("Not Found")
+			return
+		}
+	}
+
+	header := ctx.Resp.Header()
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		header.Set("Content-Type", ct)
+	}
+	if s.gzip {
+		header.Add("Vary", "Accept-Encoding")
+		if strings.Contains(ctx.Req.Header.Get("Accept-Encoding"), "gzip") {
+			if gz, gzStat, err := s.open(name + ".gz"); err == nil && !gzStat.IsDir() {
+				_ = f.Close()
+				f, stat, name = gz, gzStat, name+".gz"
+				header.Set("Content-Encoding", "gzip")
+			}
+		}
+	}
+	defer f.Close()
+
+	etag, err := s.etag(name, stat)
+	if err != nil {
+		ctx.RespStatusCode = http.StatusInternalServerError
+		ctx.RespData = []byte(http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	header.Set("ETag", etag)
+
+	if rs, ok := f.(io.ReadSeeker); ok {
+		// ServeContent 处理了 Range、If-None-Match、If-Modified-Since 等
+		http.ServeContent(ctx.Resp, ctx.Req, name, stat.ModTime(), rs)
+		return
+	}
+	// 不支持 Seek 的文件只能完整返回
+	header.Set("Content-Length", strconv.FormatInt(stat.Size(), 10))
+	ctx.Resp.WriteHeader(http.StatusOK)
+	if ctx.Req.Method != http.MethodHead {
+		_, _ = io.Copy(ctx.Resp, f)
+	}
+}
+
+func (s *staticHandler) open(name string) (fs.File, fs.FileInfo, error) {
+	f, err := s.fs.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, err
+	}
+	return f, stat, nil
+}
+
+// etag 优先使用修改时间和大小，没有修改时间的使用内容的摘要
+func (s *staticHandler) etag(name string, stat fs.FileInfo) (string, error) {
+	if !stat.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, stat.ModTime().UnixNano(), stat.Size()), nil
+	}
+	if etag, ok := s.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+	f, err := s.fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha1.New()
+	if _, err = io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)) + `"`
+	s.etags.Store(name, etag)
+	return etag, nil
+}
@@ -0,0 +1,149 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpServer_StaticFS(t *testing.T) {
+	modTime := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, err := gw.Write([]byte("console.log('gzip')"))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	fsys := fstest.MapFS{
+		"index.html":    {Data: []byte("<h1>index</h1>"), ModTime: modTime},
+		"js/app.js":     {Data: []byte("console.log('app')"), ModTime: modTime},
+		"js/app.js.gz":  {Data: gz.Bytes(), ModTime: modTime},
+		"css/style.css": {Data: []byte("body{}")},
+	}
+	server := NewHTTPServer()
+	server.StaticFS("/assets", fsys, StaticWithGzip())
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		header     map[string]string
+		wantCode   int
+		wantBody   string
+		wantHeader map[string]string
+	}{
+		{
+			name:     "file",
+			method:   http.MethodGet,
+			path:     "/assets/js/app.js",
+			wantCode: http.StatusOK,
+			wantBody: "console.log('app')",
+			wantHeader: map[string]string{
+				"Content-Type":  "text/javascript; charset=utf-8",
+				"Last-Modified": modTime.Format(http.TimeFormat),
+				"Vary":          "Accept-Encoding",
+			},
+		},
+		{
+			name:     "index",
+			method:   http.MethodGet,
+			path:     "/assets/",
+			wantCode: http.StatusOK,
+			wantBody: "<h1>index</h1>",
+		},
+		{
+			name:     "head",
+			method:   http.MethodHead,
+			path:     "/assets/index.html",
+			wantCode: http.StatusOK,
+			wantHeader: map[string]string{
+				"Content-Length": "14",
+			},
+		},
+		{
+			name:     "range",
+			method:   http.MethodGet,
+			path:     "/assets/js/app.js",
+			header:   map[string]string{"Range": "bytes=0-6"},
+			wantCode: http.StatusPartialContent,
+			wantBody: "console",
+			wantHeader: map[string]string{
+				"Content-Range": "bytes 0-6/18",
+			},
+		},
+		{
+			name:     "if modified since",
+			method:   http.MethodGet,
+			path:     "/assets/js/app.js",
+			header:   map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)},
+			wantCode: http.StatusNotModified,
+		},
+		{
+			name:     "embed etag",
+			method:   http.MethodGet,
+			path:     "/assets/css/style.css",
+			header:   map[string]string{"If-None-Match": `"9c3c5b5e3d9b5b1d4e0cbb0cc1b3b6a1ae5d6bd6"`},
+			wantCode: http.StatusOK,
+			wantBody: "body{}",
+			wantHeader: map[string]string{
+				"ETag": `"a4c0dac49e47ffe0dbcca7615f73b72ef6b71543"`,
+			},
+		},
+		{
+			name:     "embed if none match",
+			method:   http.MethodGet,
+			path:     "/assets/css/style.css",
+			header:   map[string]string{"If-None-Match": `"a4c0dac49e47ffe0dbcca7615f73b72ef6b71543"`},
+			wantCode: http.StatusNotModified,
+		},
+		{
+			name:     "gzip",
+			method:   http.MethodGet,
+			path:     "/assets/js/app.js",
+			header:   map[string]string{"Accept-Encoding": "gzip, deflate"},
+			wantCode: http.StatusOK,
+			wantBody: gz.String(),
+			wantHeader: map[string]string{
+				"Content-Type":     "text/javascript; charset=utf-8",
+				"Content-Encoding": "gzip",
+			},
+		},
+		{
+			name:     "not found",
+			method:   http.MethodGet,
+			path:     "/assets/js/not_exist.js",
+			wantCode: http.StatusNotFound,
+			wantBody: "Not Found",
+		},
+		{
+			name:     "escape root",
+			method:   http.MethodGet,
+			path:     "/assets/../../etc/passwd",
+			wantCode: http.StatusNotFound,
+			wantBody: "Not Found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+			assert.Equal(t, tc.wantBody, resp.Body.String())
+			for k, v := range tc.wantHeader {
+				assert.Equal(t, v, resp.Header().Get(k), k)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// streamBufferSize 是 RespReader 每次读取的大小，每读一次就 Flush 一次
+const streamBufferSize = 32 << 10
+
+// Stream 绕开 RespData，直接把数据写到 Resp 里面
+// 每次调用 step 之后都会 Flush，step 返回 false 或者客户端断开的时候结束
+// 状态码默认是 200，可以在调用之前设置 RespStatusCode 修改
+// 出错的时候会记录在 RespErr 里面，middleware 可以通过 RespErr、RespSize 拿到结果
+func (c *Context) Stream(contentType string, step func(w io.Writer) bool) error {
+	if contentType != "" {
+		c.Resp.Header().Set("Content-Type", contentType)
+	}
+	w := &streamWriter{ctx: c}
+	w.writeHeader()
+	for {
+		select {
+		case <-c.Done():
+			return c.streamFailed(c.Err())
+		default:
+		}
+		keepOpen := step(w)
+		if w.err != nil {
+			return c.streamFailed(w.err)
+		}
+		w.flush()
+		if !keepOpen {
+			return nil
+		}
+	}
+}
+
+// RespReader 把 r 里面的数据边读边写到 Resp 里面，不会把整个内容加载到内存
+// size 小于 0 表示长度未知，这个时候使用 chunked 编码
+func (c *Context) RespReader(r io.Reader, size int64) error {
+	if size >= 0 {
+		c.Resp.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	w := &streamWriter{ctx: c}
+	w.writeHeader()
+	buf := make([]byte, streamBufferSize)
+	for {
+		select {
+		case <-c.Done():
+			return c.streamFailed(c.Err())
+		default:
+		}
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return c.streamFailed(werr)
+			}
+			w.flush()
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return c.streamFailed(err)
+		}
+	}
+}
+
+func (c *Context) streamFailed(err error) error {
+	c.RespErr = err
+	return err
+}
+
+// streamWriter 记录写入的错误，step 里面的错误不一定会被用户返回
+type streamWriter struct {
+	ctx *Context
+	err error
+}
+
+func (w *streamWriter) writeHeader() {
+	status := w.ctx.RespStatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.ctx.Resp.WriteHeader(status)
+}
+
+func (w *streamWriter) Write(data []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.ctx.Resp.Write(data)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+func (w *streamWriter) flush() {
+	if f, ok := w.ctx.Resp.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushes++
+	f.ResponseRecorder.Flush()
+}
+
+func TestContext_Stream(t *testing.T) {
+	var code, size int
+	var respErr error
+	server := NewHTTPServer(ServerWithMiddleware(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			code, size, respErr = ctx.RespStatusCode, ctx.RespSize(), ctx.RespErr
+		}
+	}))
+	server.Get("/export", func(ctx *Context) {
+		i := 0
+		_ = ctx.Stream("text/csv", func(w io.Writer) bool {
+			_, _ = fmt.Fprintf(w, "%d,Tom\n", i)
+			i++
+			return i < 3
+		})
+	})
+	server.Get("/download", func(ctx *Context) {
+		ctx.RespStatusCode = http.StatusAccepted
+		_ = ctx.RespReader(strings.NewReader(strings.Repeat("a", streamBufferSize+10)), -1)
+	})
+
+	resp := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	server.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/export", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "text/csv", resp.Header().Get("Content-Type"))
+	assert.Equal(t, "0,Tom\n1,Tom\n2,Tom\n", resp.Body.String())
+	assert.Equal(t, 3, resp.flushes)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 18, size)
+	assert.NoError(t, respErr)
+
+	resp = &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	server.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/download", nil))
+	assert.Equal(t, http.StatusAccepted, resp.Code)
+	assert.Equal(t, streamBufferSize+10, resp.Body.Len())
+	assert.Equal(t, 2, resp.flushes)
+	assert.Equal(t, http.StatusAccepted, code)
+	assert.Equal(t, streamBufferSize+10, size)
+	assert.NoError(t, respErr)
+}
+
+func TestContext_Stream_Canceled(t *testing.T) {
+	reqCtx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/export", nil).WithContext(reqCtx)
+	resp := httptest.NewRecorder()
+	ctx := &Context{Req: req, Resp: resp}
+	i := 0
+	err := ctx.Stream("", func(w io.Writer) bool {
+		i++
+		// 模拟客户端断开
+		cancel()
+		return true
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, context.Canceled, ctx.RespErr)
+	assert.Equal(t, 1, i)
+
+	ctx = &Context{Req: httptest.NewRequest(http.MethodGet, "/download", nil), Resp: httptest.NewRecorder()}
+	readErr := errors.New("read failed")
+	err = ctx.RespReader(io.MultiReader(strings.NewReader("abc"), &errReader{err: readErr}), 10)
+	require.Equal(t, readErr, err)
+	assert.Equal(t, readErr, ctx.RespErr)
+}
+
+type errReader struct {
+	err error
+}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	return 0, e.err
+}
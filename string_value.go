@@ -0,0 +1,203 @@
+package web
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// KeyNotFoundError 表示请求里面没有这个 key，Source 是 path、query、form 或者 header
+type KeyNotFoundError struct {
+	Source string
+	Key    string
+}
+
+func (e *KeyNotFoundError) Error() string {
+	return fmt.Sprintf("web: %s 中 key [%s] 不存在", e.Source, e.Key)
+}
+
+// ConvertError 表示 key 的值转换成目标类型失败，Err 是 strconv、time 返回的原始错误
+type ConvertError struct {
+	Source string
+	Key    string
+	Err    error
+}
+
+func (e *ConvertError) Error() string {
+	return fmt.Sprintf("web: %s 中 key [%s] 转换失败: %v", e.Source, e.Key, e.Err)
+}
+
+func (e *ConvertError) Unwrap() error {
+	return e.Err
+}
+
+func (c *Context) PathValue1(key string) StringValue {
+	val, ok := c.PathParams[key]
+	if !ok {
+		return StringValue{
+			err: &KeyNotFoundError{Source: "path", Key: key},
+		}
+	}
+	return StringValue{
+		source: "path",
+		key:    key,
+		vals:   []string{val},
+	}
+}
+
+// QueryValue1 支持同一个 key 出现多次，例如 ?id=1&id=2，可以用 AsStrings 拿到全部的值
+func (c *Context) QueryValue1(key string) StringValue {
+	vals, ok := c.query()[key]
+	if !ok {
+		return StringValue{
+			err: &KeyNotFoundError{Source: "query", Key: key},
+		}
+	}
+	return StringValue{
+		source: "query",
+		key:    key,
+		vals:   vals,
+	}
+}
+
+// FormValue1 和 FormValue 一样，使用的是包含了查询参数的 Req.Form
+func (c *Context) FormValue1(key string) StringValue {
+	if err := c.Req.ParseForm(); err != nil {
+		return StringValue{source: "form", key: key, err: err}
+	}
+	vals, ok := c.Req.Form[key]
+	if !ok {
+		return StringValue{
+			err: &KeyNotFoundError{Source: "form", Key: key},
+		}
+	}
+	return StringValue{
+		source: "form",
+		key:    key,
+		vals:   vals,
+	}
+}
+
+// HeaderValue1 的 key 大小写不敏感
+func (c *Context) HeaderValue1(key string) StringValue {
+	vals := c.Req.Header.Values(key)
+	if len(vals) == 0 {
+		return StringValue{
+			err: &KeyNotFoundError{Source: "header", Key: key},
+		}
+	}
+	return StringValue{
+		source: "header",
+		key:    key,
+		vals:   vals,
+	}
+}
+
+// StringValue 把各种来源的字符串转换成需要的类型
+// 有多个值的时候，除了 AsStrings，其余方法都只使用第一个值
+// 转换失败的时候返回 ConvertError，带上来源和 key
+type StringValue struct {
+	source string
+	key    string
+	vals   []string
+	err    error
+}
+
+// Or 在 key 不存在的时候使用默认值，其余的错误不受影响
+func (s StringValue) Or(def string) StringValue {
+	if _, ok := s.err.(*KeyNotFoundError); ok || (s.err == nil && len(s.vals) == 0) {
+		return StringValue{source: s.source, key: s.key, vals: []string{def}}
+	}
+	return s
+}
+
+// first 返回第一个值，零值的 StringValue 当作 key 不存在
+func (s StringValue) first() (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	if len(s.vals) == 0 {
+		return "", &KeyNotFoundError{Source: s.source, Key: s.key}
+	}
+	return s.vals[0], nil
+}
+
+func (s StringValue) convertErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ConvertError{Source: s.source, Key: s.key, Err: err}
+}
+
+func (s StringValue) AsString() (string, error) {
+	return s.first()
+}
+
+func (s StringValue) AsStrings() ([]string, error) {
+	if _, err := s.first(); err != nil {
+		return nil, err
+	}
+	return s.vals, nil
+}
+
+func (s StringValue) AsInt64() (int64, error) {
+	val, err := s.first()
+	if err != nil {
+		return 0, err
+	}
+	res, err := strconv.ParseInt(val, 10, 64)
+	return res, s.convertErr(err)
+}
+
+func (s StringValue) AsUint64() (uint64, error) {
+	val, err := s.first()
+	if err != nil {
+		return 0, err
+	}
+	res, err := strconv.ParseUint(val, 10, 64)
+	return res, s.convertErr(err)
+}
+
+func (s StringValue) AsFloat64() (float64, error) {
+	val, err := s.first()
+	if err != nil {
+		return 0, err
+	}
+	res, err := strconv.ParseFloat(val, 64)
+	return res, s.convertErr(err)
+}
+
+// AsBool 支持 strconv.ParseBool 能够识别的格式，例如 1、t、true
+func (s StringValue) AsBool() (bool, error) {
+	val, err := s.first()
+	if err != nil {
+		return false, err
+	}
+	res, err := strconv.ParseBool(val)
+	return res, s.convertErr(err)
+}
+
+// AsTime 使用 time.Parse 解析，layout 例如 time.RFC3339、"2006-01-02"
+func (s StringValue) AsTime(layout string) (time.Time, error) {
+	val, err := s.first()
+	if err != nil {
+		return time.Time{}, err
+	}
+	res, err := time.Parse(layout, val)
+	return res, s.convertErr(err)
+}
+
+// AsDuration 使用 time.ParseDuration 解析，例如 300ms、1h30m
+func (s StringValue) AsDuration() (time.Duration, error) {
+	val, err := s.first()
+	if err != nil {
+		return 0, err
+	}
+	res, err := time.ParseDuration(val)
+	return res, s.convertErr(err)
+}
+
+// 不能用泛型
+// func (s StringValue) To[T any]() (T, error) {
+//
+// }
@@ -0,0 +1,97 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringValue(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost,
+		"/user/12?id=1&id=2&price=9.9&vip=true&birthday=2000-01-02&timeout=1m30s&uid=abc",
+		strings.NewReader("name=Tom&name=Jerry"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Tenant", "geektime")
+	ctx := &Context{Req: req, PathParams: map[string]string{"id": "12"}}
+
+	id, err := ctx.PathValue1("id").AsInt64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), id)
+
+	uid, err := ctx.PathValue1("id").AsUint64()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(12), uid)
+
+	ids, err := ctx.QueryValue1("id").AsStrings()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, ids)
+
+	first, err := ctx.QueryValue1("id").AsInt64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), first)
+
+	price, err := ctx.QueryValue1("price").AsFloat64()
+	require.NoError(t, err)
+	assert.Equal(t, 9.9, price)
+
+	vip, err := ctx.QueryValue1("vip").AsBool()
+	require.NoError(t, err)
+	assert.True(t, vip)
+
+	birthday, err := ctx.QueryValue1("birthday").AsTime("2006-01-02")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC), birthday)
+
+	timeout, err := ctx.QueryValue1("timeout").AsDuration()
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Second, timeout)
+
+	names, err := ctx.FormValue1("name").AsStrings()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Tom", "Jerry"}, names)
+
+	tenant, err := ctx.HeaderValue1("x-tenant").AsString()
+	require.NoError(t, err)
+	assert.Equal(t, "geektime", tenant)
+
+	// 默认值
+	page, err := ctx.QueryValue1("page").Or("1").AsInt64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), page)
+	// 存在的 key 不会使用默认值
+	first, err = ctx.QueryValue1("id").Or("100").AsInt64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), first)
+	// 转换失败的错误不会被默认值掩盖，并且带上来源和 key
+	_, err = ctx.QueryValue1("uid").Or("1").AsInt64()
+	var convErr *ConvertError
+	require.ErrorAs(t, err, &convErr)
+	assert.Equal(t, "query", convErr.Source)
+	assert.Equal(t, "uid", convErr.Key)
+	assert.ErrorIs(t, err, strconv.ErrSyntax)
+
+	// 零值不会 panic
+	_, err = StringValue{}.AsInt64()
+	assert.Equal(t, &KeyNotFoundError{}, err)
+	_, err = StringValue{}.AsStrings()
+	assert.Equal(t, &KeyNotFoundError{}, err)
+	zero, err := StringValue{}.Or("2").AsInt64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), zero)
+
+	// 缺失的 key 带上来源和名字
+	_, err = ctx.HeaderValue1("X-Not-Exist").AsString()
+	var notFound *KeyNotFoundError
+	require.ErrorAs(t, err, &notFound)
+	assert.Equal(t, &KeyNotFoundError{Source: "header", Key: "X-Not-Exist"}, notFound)
+	assert.EqualError(t, err, "web: header 中 key [X-Not-Exist] 不存在")
+
+	_, err = ctx.QueryValue("not_exist")
+	assert.Equal(t, &KeyNotFoundError{Source: "query", Key: "not_exist"}, err)
+}
@@ -0,0 +1,197 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"sync"
+)
+
+// 确保 GoTemplateEngine 实现了 TemplateEngine
+var _ TemplateEngine = &GoTemplateEngine{}
+
+var ErrNoTemplateEngine = errors.New("web: 没有设置模板引擎，请使用 ServerWithTemplateEngine")
+
+// TemplateEngine 是模板引擎的抽象，tplName 是模板的名字，具体的含义由实现决定
+// 返回渲染好的字节，渲染失败的时候不会有半截的输出写到响应里
+type TemplateEngine interface {
+	Render(ctx context.Context, tplName string, data any) ([]byte, error)
+}
+
+func ServerWithTemplateEngine(engine TemplateEngine) HTTPServerOption {
+	return func(server *HttpServer) {
+		server.tplEngine = engine
+	}
+}
+
+// RenderTemplate 用 HttpServer 上的模板引擎渲染 tplName，结果放到 RespData
+// Content-Type 是 text/html; charset=utf-8，如果需要别的类型可以在调用之后覆盖
+func (c *Context) RenderTemplate(status int, tplName string, data any) error {
+	if c.tplEngine == nil {
+		return ErrNoTemplateEngine
+	}
+	res, err := c.tplEngine.Render(c, tplName, data)
+	if err != nil {
+		return err
+	}
+	c.Resp.Header().Set("Content-Type", "text/html; charset=utf-8")
+	c.RespStatusCode = status
+	c.RespData = res
+	return nil
+}
+
+type GoTemplateOption func(e *GoTemplateEngine)
+
+// GoTemplateWithLayout 所有的页面都套用 layout 渲染
+// 页面里面用 {{define "content"}} 之类的定义 block，layout 里面用 {{template "content" .}} 引用
+func GoTemplateWithLayout(layout string) GoTemplateOption {
+	return func(e *GoTemplateEngine) {
+		e.layout = layout
+	}
+}
+
+// GoTemplateWithPartials 匹配 patterns 的文件会被加载到每一个页面里面，可以在任意页面中引用
+func GoTemplateWithPartials(patterns ...string) GoTemplateOption {
+	return func(e *GoTemplateEngine) {
+		e.partials = append(e.partials, patterns...)
+	}
+}
+
+// GoTemplateWithFuncs 注册模板里面可以使用的函数
+func GoTemplateWithFuncs(funcs template.FuncMap) GoTemplateOption {
+	return func(e *GoTemplateEngine) {
+		for name, fn := range funcs {
+			e.funcs[name] = fn
+		}
+	}
+}
+
+// GoTemplateWithReload 每次渲染之前都重新从 fs 里面加载模板，改了文件不用重启
+// 只适合开发环境使用
+func GoTemplateWithReload() GoTemplateOption {
+	return func(e *GoTemplateEngine) {
+		e.reload = true
+	}
+}
+
+// GoTemplateEngine 是基于 html/template 的实现
+// 每个页面是一个独立的模板集合，包含 layout、partials 和页面自己，所以不同页面里面同名的 block 不会冲突
+// 模板的名字就是文件在 fs 里面的路径，例如 user/profile.gohtml
+type GoTemplateEngine struct {
+	fsys     fs.FS
+	pages    string
+	layout   string
+	partials []string
+	funcs    template.FuncMap
+	reload   bool
+
+	mutex sync.RWMutex
+	tpls  map[string]*template.Template
+}
+
+// NewGoTemplateEngine 加载 fsys 里面匹配 pages 的文件作为页面，pages 的语法和 fs.Glob 一致
+// 开发环境下可以配合 os.DirFS 和 GoTemplateWithReload 使用，线上可以直接用 embed.FS
+func NewGoTemplateEngine(fsys fs.FS, pages string, opts ...GoTemplateOption) (*GoTemplateEngine, error) {
+	res := &GoTemplateEngine{
+		fsys:  fsys,
+		pages: pages,
+		funcs: template.FuncMap{},
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	tpls, err := res.load()
+	if err != nil {
+		return nil, err
+	}
+	res.tpls = tpls
+	return res, nil
+}
+
+func (e *GoTemplateEngine) Render(ctx context.Context, tplName string, data any) ([]byte, error) {
+	tpls := e.templates()
+	if e.reload {
+		var err error
+		if tpls, err = e.load(); err != nil {
+			return nil, err
+		}
+		e.mutex.Lock()
+		e.tpls = tpls
+		e.mutex.Unlock()
+	}
+	tpl, ok := tpls[tplName]
+	if !ok {
+		return nil, fmt.Errorf("web: 模板 [%s] 不存在", tplName)
+	}
+	name := tplName
+	if e.layout != "" {
+		name = e.layout
+	}
+	var buf bytes.Buffer
+	if err := tpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *GoTemplateEngine) templates() map[string]*template.Template {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.tpls
+}
+
+func (e *GoTemplateEngine) load() (map[string]*template.Template, error) {
+	// layout 和 partials 是公共的部分，每个页面 Clone 一份
+	base := template.New("").Funcs(e.funcs)
+	shared := make(map[string]struct{})
+	if e.layout != "" {
+		if err := e.parseFile(base, e.layout); err != nil {
+			return nil, err
+		}
+		shared[e.layout] = struct{}{}
+	}
+	for _, pattern := range e.partials {
+		names, err := fs.Glob(e.fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if err = e.parseFile(base, name); err != nil {
+				return nil, err
+			}
+			shared[name] = struct{}{}
+		}
+	}
+
+	names, err := fs.Glob(e.fsys, e.pages)
+	if err != nil {
+		return nil, err
+	}
+	tpls := make(map[string]*template.Template, len(names))
+	for _, name := range names {
+		if _, ok := shared[name]; ok {
+			continue
+		}
+		tpl, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if err = e.parseFile(tpl, name); err != nil {
+			return nil, err
+		}
+		tpls[name] = tpl
+	}
+	return tpls, nil
+}
+
+func (e *GoTemplateEngine) parseFile(tpl *template.Template, name string) error {
+	content, err := fs.ReadFile(e.fsys, name)
+	if err != nil {
+		return err
+	}
+	_, err = tpl.New(name).Parse(string(content))
+	return err
+}
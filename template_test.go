@@ -0,0 +1,88 @@
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoTemplateEngine(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layouts/base.gohtml": {Data: []byte(`<html>{{template "nav" .}}{{template "content" .}}</html>`)},
+		"partials/nav.gohtml": {Data: []byte(`{{define "nav"}}<nav>{{.Name | upper}}</nav>{{end}}`)},
+		"user/profile.gohtml": {Data: []byte(`{{define "content"}}<p>{{.Name}}</p>{{end}}`)},
+		"user/list.gohtml":    {Data: []byte(`{{define "content"}}<ul>{{range .Names}}<li>{{.}}</li>{{end}}</ul>{{end}}`)},
+	}
+	engine, err := NewGoTemplateEngine(fsys, "user/*.gohtml",
+		GoTemplateWithLayout("layouts/base.gohtml"),
+		GoTemplateWithPartials("partials/*.gohtml"),
+		GoTemplateWithFuncs(template.FuncMap{"upper": strings.ToUpper}))
+	require.NoError(t, err)
+
+	server := NewHTTPServer(ServerWithTemplateEngine(engine))
+	server.Get("/profile", func(ctx *Context) {
+		_ = ctx.RenderTemplate(http.StatusOK, "user/profile.gohtml", map[string]string{"Name": "<tom>"})
+	})
+	server.Get("/list", func(ctx *Context) {
+		_ = ctx.RenderTemplate(http.StatusOK, "user/list.gohtml", map[string]any{"Name": "", "Names": []string{"a", "b"}})
+	})
+
+	testCases := []struct {
+		name     string
+		path     string
+		wantBody string
+	}{
+		{
+			// 自动转义
+			name:     "profile",
+			path:     "/profile",
+			wantBody: "<html><nav>&lt;TOM&gt;</nav><p>&lt;tom&gt;</p></html>",
+		},
+		{
+			// 同名的 content 不会互相覆盖
+			name:     "list",
+			path:     "/list",
+			wantBody: "<html><nav></nav><ul><li>a</li><li>b</li></ul></html>",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, "text/html; charset=utf-8", resp.Header().Get("Content-Type"))
+			assert.Equal(t, tc.wantBody, resp.Body.String())
+		})
+	}
+
+	ctx := &Context{Req: httptest.NewRequest(http.MethodGet, "/", nil), Resp: httptest.NewRecorder(), tplEngine: engine}
+	assert.EqualError(t, ctx.RenderTemplate(http.StatusOK, "user/none.gohtml", nil), "web: 模板 [user/none.gohtml] 不存在")
+	ctx.tplEngine = nil
+	assert.Equal(t, ErrNoTemplateEngine, ctx.RenderTemplate(http.StatusOK, "user/profile.gohtml", nil))
+}
+
+func TestGoTemplateEngine_Reload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "index.gohtml")
+	require.NoError(t, os.WriteFile(file, []byte(`v1 {{.}}`), 0o600))
+	engine, err := NewGoTemplateEngine(os.DirFS(dir), "*.gohtml", GoTemplateWithReload())
+	require.NoError(t, err)
+
+	res, err := engine.Render(&Context{}, "index.gohtml", "tom")
+	require.NoError(t, err)
+	assert.Equal(t, "v1 tom", string(res))
+
+	// 修改文件之后不需要重启
+	require.NoError(t, os.WriteFile(file, []byte(`v2 {{.}}`), 0o600))
+	res, err = engine.Render(&Context{}, "index.gohtml", "tom")
+	require.NoError(t, err)
+	assert.Equal(t, "v2 tom", string(res))
+}
@@ -0,0 +1,287 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+var errNoCertificate = errors.New("web: 没有配置 TLS 证书")
+
+// CertProvider 在 TLS 握手的时候提供证书，可以在不重启的情况下更换证书
+type CertProvider interface {
+	GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error)
+	// NotAfter 是当前证书的过期时间，用于监控
+	NotAfter() time.Time
+}
+
+// ServerWithTLS 设置 TLS 的配置，cfg 和 provider 都可以为 nil
+// provider 不为 nil 的时候会覆盖 cfg.GetCertificate
+func ServerWithTLS(cfg *tls.Config, provider CertProvider) HTTPServerOption {
+	return func(server *HttpServer) {
+		server.tlsConfig = cfg
+		server.certProvider = provider
+	}
+}
+
+// ServerWithSelfSignedCert 在内存里面生成一个自签名的证书，只适合开发环境使用
+// hosts 是证书里面的域名或者 IP，默认是 localhost、127.0.0.1 和 ::1
+func ServerWithSelfSignedCert(hosts ...string) HTTPServerOption {
+	return func(server *HttpServer) {
+		if len(hosts) == 0 {
+			hosts = []string{"localhost", "127.0.0.1", "::1"}
+		}
+		server.selfSignedHosts = hosts
+	}
+}
+
+// StartTLS 启动 HTTPS 服务器，ServerWithListener 添加的 listener 也使用 TLS
+// certFile、keyFile 改变之后会自动重新加载，不需要重启
+// 两个都为空的时候使用 ServerWithTLS、ServerWithSelfSignedCert 设置的证书
+func (h *HttpServer) StartTLS(addr string, certFile string, keyFile string) error {
+	if addr == "" {
+		addr = ":https"
+	}
+	cfg, err := h.buildTLSConfig(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	lns, err := h.listenAll(addr, func(ln net.Listener) net.Listener {
+		return tls.NewListener(ln, cfg)
+	})
+	if err != nil {
+		return err
+	}
+	return h.serve(lns...)
+}
+
+// CertNotAfter 返回正在使用的证书的过期时间，没有启用 TLS 的时候返回零值
+func (h *HttpServer) CertNotAfter() time.Time {
+	active := h.activeCert.Load()
+	if active == nil {
+		return time.Time{}
+	}
+	return active.provider.NotAfter()
+}
+
+// activeCert 包一层，不同的 CertProvider 实现可以存到同一个 atomic.Pointer 里面
+type activeCert struct {
+	provider CertProvider
+}
+
+func (h *HttpServer) buildTLSConfig(certFile string, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if h.tlsConfig != nil {
+		cfg = h.tlsConfig.Clone()
+	}
+	var err error
+	provider := h.certProvider
+	switch {
+	case certFile != "" || keyFile != "":
+		provider, err = NewCertReloader(certFile, keyFile)
+	case provider == nil && len(h.selfSignedHosts) > 0:
+		provider, err = NewSelfSignedCert(h.selfSignedHosts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if provider != nil {
+		cfg.GetCertificate = provider.GetCertificate
+	} else if len(cfg.Certificates) > 0 {
+		// 固定的证书只用来监控过期时间，握手还是交给 tls 自己根据 SNI 选择
+		if provider, err = newStaticCert(cfg.Certificates[0]); err != nil {
+			return nil, err
+		}
+	} else if cfg.GetCertificate == nil {
+		return nil, errNoCertificate
+	}
+	if provider != nil {
+		h.activeCert.Store(&activeCert{provider: provider})
+	}
+	if h.clientAuth != tls.NoClientCert {
+		cfg.ClientAuth = h.clientAuth
+		cfg.ClientCAs = h.clientCAs
+	}
+	// 和 http.Server.ServeTLS 一样默认开启 HTTP/2
+	if len(cfg.NextProtos) == 0 {
+		cfg.NextProtos = []string{"h2", "http/1.1"}
+	}
+	return cfg, nil
+}
+
+type CertReloaderOption func(r *CertReloader)
+
+// CertReloaderWithInterval 设置检查文件是否变化的最小间隔，默认是 10 秒
+func CertReloaderWithInterval(interval time.Duration) CertReloaderOption {
+	return func(r *CertReloader) {
+		r.interval = interval
+	}
+}
+
+// CertReloader 从文件加载证书，文件的修改时间变化之后重新加载
+// 检查是在握手的时候做的，并且最多每 interval 检查一次
+// 重新加载失败的时候（例如只更新了证书还没有更新私钥）继续使用旧的证书，下次检查的时候重试
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mutex     sync.RWMutex
+	cert      *tls.Certificate
+	notAfter  time.Time
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func NewCertReloader(certFile string, keyFile string, opts ...CertReloaderOption) (*CertReloader, error) {
+	res := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	if err := res.Reload(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.RLock()
+	cert, lastCheck := r.cert, r.lastCheck
+	r.mutex.RUnlock()
+	if time.Since(lastCheck) < r.interval {
+		return cert, nil
+	}
+	r.mutex.Lock()
+	r.lastCheck = time.Now()
+	r.mutex.Unlock()
+	if modTime, err := r.latestModTime(); err == nil && !modTime.Equal(r.currentModTime()) {
+		_ = r.Reload()
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.cert, nil
+}
+
+func (r *CertReloader) NotAfter() time.Time {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.notAfter
+}
+
+// Reload 立刻重新加载证书
+func (r *CertReloader) Reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+	cert.Leaf = leaf
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cert = &cert
+	r.notAfter = leaf.NotAfter
+	r.modTime = modTime
+	r.lastCheck = time.Now()
+	return nil
+}
+
+func (r *CertReloader) currentModTime() time.Time {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.modTime
+}
+
+// latestModTime 是证书和私钥两个文件里面较新的修改时间
+func (r *CertReloader) latestModTime() (time.Time, error) {
+	var res time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(res) {
+			res = info.ModTime()
+		}
+	}
+	return res, nil
+}
+
+// staticCert 是固定不变的证书
+type staticCert struct {
+	cert     *tls.Certificate
+	notAfter time.Time
+}
+
+func newStaticCert(cert tls.Certificate) (*staticCert, error) {
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, err
+		}
+	}
+	return &staticCert{cert: &cert, notAfter: leaf.NotAfter}, nil
+}
+
+func (s *staticCert) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return s.cert, nil
+}
+
+func (s *staticCert) NotAfter() time.Time {
+	return s.notAfter
+}
+
+// NewSelfSignedCert 在内存里面生成一个 ECDSA 的自签名证书，有效期 30 天
+func NewSelfSignedCert(hosts ...string) (CertProvider, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"web dev"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(30 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			tpl.IPAddresses = append(tpl.IPAddresses, ip)
+		} else {
+			tpl.DNSNames = append(tpl.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return newStaticCert(tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key})
+}
@@ -0,0 +1,101 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpServer_TLS(t *testing.T) {
+	server := NewHTTPServer(ServerWithSelfSignedCert())
+	server.Get("/hello", func(ctx *Context) {
+		ctx.RespData = []byte(ctx.Req.Proto + " " + ctx.ListenerName())
+	})
+	cfg, err := server.buildTLSConfig("", "")
+	require.NoError(t, err)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = server.serve(tls.NewListener(&namedListener{Listener: ln, name: DefaultListener}, cfg))
+	}()
+	defer server.srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := client.Get("https://" + ln.Addr().String() + "/hello")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	// 默认开启 HTTP/2，TLS 的连接也可以拿到 listener 的名字
+	assert.Equal(t, "HTTP/2.0 default", string(body))
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), server.CertNotAfter(), time.Minute)
+
+	// 换成另外一种 CertProvider 也可以
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	leaf := writeTestCert(t, certFile, keyFile)
+	_, err = server.buildTLSConfig(certFile, keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, leaf.NotAfter, server.CertNotAfter())
+
+	_, err = NewHTTPServer().buildTLSConfig("", "")
+	assert.Equal(t, errNoCertificate, err)
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	first := writeTestCert(t, certFile, keyFile)
+	reloader, err := NewCertReloader(certFile, keyFile, CertReloaderWithInterval(0))
+	require.NoError(t, err)
+	cert, err := reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, first.SerialNumber, cert.Leaf.SerialNumber)
+
+	// 文件变化之后不需要重启
+	second := writeTestCert(t, certFile, keyFile)
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	cert, err = reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, second.SerialNumber, cert.Leaf.SerialNumber)
+	assert.Equal(t, second.NotAfter, reloader.NotAfter())
+
+	// 私钥写坏了，继续用旧的证书
+	require.NoError(t, os.WriteFile(keyFile, []byte("broken"), 0o600))
+	future = future.Add(time.Hour)
+	require.NoError(t, os.Chtimes(keyFile, future, future))
+	cert, err = reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, second.SerialNumber, cert.Leaf.SerialNumber)
+}
+
+// writeTestCert 生成一个新的证书写到文件里面
+func writeTestCert(t *testing.T, certFile string, keyFile string) *x509.Certificate {
+	provider, err := NewSelfSignedCert("localhost")
+	require.NoError(t, err)
+	cert, err := provider.GetCertificate(nil)
+	require.NoError(t, err)
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600))
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf
+}
@@ -0,0 +1,31 @@
+//go:build !unix
+
+package web
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+var errUpgradeUnsupported = errors.New("web: 当前平台不支持平滑升级")
+
+// ServerWithGracefulUpgrade 在当前平台上不生效
+func ServerWithGracefulUpgrade(timeout time.Duration, signals ...os.Signal) HTTPServerOption {
+	return func(server *HttpServer) {}
+}
+
+func (h *HttpServer) watchUpgrade() {}
+
+// Upgrade 在当前平台上总是返回错误
+func (h *HttpServer) Upgrade(ctx context.Context) error {
+	return errUpgradeUnsupported
+}
+
+func inheritedListener(addr string) (net.Listener, bool) {
+	return nil, false
+}
+
+func notifyUpgradeReady() {}
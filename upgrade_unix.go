@@ -0,0 +1,206 @@
+//go:build unix
+
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	envUpgradeListeners = "WEB_UPGRADE_LISTENERS"
+	envUpgradeReady     = "WEB_UPGRADE_READY"
+)
+
+// 启动的时候就记下来，升级的时候磁盘上的文件已经被替换了，/proc/self/exe 指向的是旧的文件
+var upgradeExecutable, _ = os.Executable()
+
+// upgradeCommand 创建新进程的命令，和当前进程同样的参数、标准输入输出，测试的时候会替换掉
+var upgradeCommand = func() *exec.Cmd {
+	cmd := exec.Command(upgradeExecutable, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd
+}
+
+// ServerWithGracefulUpgrade 收到 signals 的时候平滑升级，默认是 SIGHUP 和 SIGUSR2
+// 重新执行当前的二进制，并把 Start、StartTLS 的 listener 传给它
+// 新的进程用同样的地址调用 Start，就会直接使用传过来的 listener，开始处理请求之后当前进程在 timeout 内优雅退出
+func ServerWithGracefulUpgrade(timeout time.Duration, signals ...os.Signal) HTTPServerOption {
+	return func(server *HttpServer) {
+		if len(signals) == 0 {
+			signals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR2}
+		}
+		server.upgradeTimeout = timeout
+		server.upgradeSignals = signals
+	}
+}
+
+func (h *HttpServer) watchUpgrade() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, h.upgradeSignals...)
+	go func() {
+		for sig := range ch {
+			h.logger.Info("开始平滑升级", "signal", sig.String())
+			ctx, cancel := context.WithTimeout(context.Background(), h.upgradeTimeout)
+			err := h.Upgrade(ctx)
+			cancel()
+			if err != nil {
+				// 升级失败，当前进程继续提供服务
+				h.logger.Error("平滑升级失败", "error", err)
+				continue
+			}
+			signal.Stop(ch)
+			h.logger.Info("新的进程已经就绪，当前进程退出")
+			ctx, cancel = context.WithTimeout(context.Background(), h.upgradeTimeout)
+			if err = h.Shutdown(ctx); err != nil {
+				h.logger.Error("平滑升级之后退出失败", "error", err)
+			}
+			cancel()
+			return
+		}
+	}()
+}
+
+// Upgrade 启动新的进程并把 listener 传给它，新的进程开始处理请求之后返回
+// 新旧两个进程在这期间共享同一个 listener，所以不会丢失连接
+// ctx 到期之前新的进程还没有准备好的话，会杀掉它并返回错误，当前进程不受影响
+func (h *HttpServer) Upgrade(ctx context.Context) error {
+	h.lnMutex.Lock()
+	addrs := make([]string, 0, len(h.listeners))
+	files := make([]*os.File, 0, len(h.listeners)+1)
+	var err error
+	for addr, ln := range h.listeners {
+		filer, ok := ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			err = fmt.Errorf("web: 地址 [%s] 的 listener 不支持传递给子进程", addr)
+			break
+		}
+		var f *os.File
+		if f, err = filer.File(); err != nil {
+			break
+		}
+		addrs = append(addrs, addr)
+		files = append(files, f)
+	}
+	h.lnMutex.Unlock()
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("web: 没有可以传递给子进程的 listener")
+	}
+
+	// 子进程准备好之后往管道里面写一个字节
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	cmd := upgradeCommand()
+	// ExtraFiles 在子进程里面从 3 开始
+	cmd.ExtraFiles = append(files, w)
+	cmd.Env = append(os.Environ(),
+		envUpgradeListeners+"="+strings.Join(addrs, ","),
+		envUpgradeReady+"="+strconv.Itoa(3+len(files)))
+	err = cmd.Start()
+	_ = w.Close()
+	if err != nil {
+		return err
+	}
+	// 子进程退出了也要回收，避免变成僵尸进程
+	go func() {
+		_ = cmd.Wait()
+	}()
+
+	ready := make(chan error, 1)
+	go func() {
+		_, err := r.Read(make([]byte, 1))
+		ready <- err
+	}()
+	select {
+	case err = <-ready:
+		if err != nil {
+			_ = cmd.Process.Kill()
+			return fmt.Errorf("web: 新的进程启动失败: %w", err)
+		}
+	case <-ctx.Done():
+		_ = cmd.Process.Kill()
+		return ctx.Err()
+	}
+
+	// 当前进程退出的时候不能删掉 unix socket 文件，新的进程还在用
+	h.lnMutex.Lock()
+	defer h.lnMutex.Unlock()
+	for _, ln := range h.listeners {
+		if ul, ok := ln.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	return nil
+}
+
+var (
+	inheritOnce  sync.Once
+	inheritMutex sync.Mutex
+	inherited    map[string]net.Listener
+	upgradeReady *os.File
+)
+
+// loadInherited 解析父进程在 Upgrade 里面传过来的 listener
+func loadInherited() {
+	addrs := os.Getenv(envUpgradeListeners)
+	if addrs == "" {
+		return
+	}
+	list := strings.Split(addrs, ",")
+	if lns, err := fdListeners(3, len(list)); err == nil {
+		inherited = make(map[string]net.Listener, len(lns))
+		for i, ln := range lns {
+			inherited[list[i]] = ln
+		}
+	}
+	if fd, err := strconv.Atoi(os.Getenv(envUpgradeReady)); err == nil {
+		upgradeReady = os.NewFile(uintptr(fd), "upgrade-ready")
+	}
+	// 避免再往下传给别的子进程
+	_ = os.Unsetenv(envUpgradeListeners)
+	_ = os.Unsetenv(envUpgradeReady)
+}
+
+// inheritedListener 返回父进程传下来的 addr 对应的 listener，每个只能用一次
+func inheritedListener(addr string) (net.Listener, bool) {
+	inheritOnce.Do(loadInherited)
+	inheritMutex.Lock()
+	defer inheritMutex.Unlock()
+	ln, ok := inherited[addr]
+	delete(inherited, addr)
+	return ln, ok
+}
+
+// notifyUpgradeReady 告诉父进程可以退出了，只有第一次调用有效
+func notifyUpgradeReady() {
+	inheritOnce.Do(loadInherited)
+	inheritMutex.Lock()
+	defer inheritMutex.Unlock()
+	if upgradeReady == nil {
+		return
+	}
+	_, _ = upgradeReady.Write([]byte{1})
+	_ = upgradeReady.Close()
+	upgradeReady = nil
+}
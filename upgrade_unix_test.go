@@ -0,0 +1,85 @@
+//go:build unix
+
+package web
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUpgradeChild 是升级之后的子进程，只在 TestHttpServer_Upgrade 里面被启动
+func TestUpgradeChild(t *testing.T) {
+	if os.Getenv("WEB_TEST_UPGRADE_CHILD") == "" {
+		t.Skip("只在子进程里面运行")
+	}
+	server := NewHTTPServer()
+	server.Get("/pid", func(ctx *Context) {
+		ctx.RespData = []byte(strconv.Itoa(os.Getpid()))
+	})
+	// 和父进程同样的地址
+	_ = server.Start("127.0.0.1:0")
+}
+
+func TestHttpServer_Upgrade(t *testing.T) {
+	oldCommand := upgradeCommand
+	var cmd *exec.Cmd
+	t.Cleanup(func() {
+		upgradeCommand = oldCommand
+		// 不管测试在哪里失败，子进程都不能留下来
+		if cmd != nil && cmd.Process != nil {
+			_ = cmd.Process.Kill()
+		}
+	})
+	upgradeCommand = func() *exec.Cmd {
+		// 不继承标准输出，否则子进程会一直占着 go test 的输出管道
+		cmd = exec.Command(upgradeExecutable, "-test.run=^TestUpgradeChild$")
+		return cmd
+	}
+	t.Setenv("WEB_TEST_UPGRADE_CHILD", "1")
+
+	server := NewHTTPServer()
+	server.Get("/pid", func(ctx *Context) {
+		ctx.RespData = []byte(strconv.Itoa(os.Getpid()))
+	})
+	addrCh := make(chan net.Addr, 1)
+	server.OnAfterStart(time.Second, func(ctx context.Context, addr net.Addr) error {
+		addrCh <- addr
+		return nil
+	})
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Start("127.0.0.1:0")
+	}()
+	url := "http://" + (<-addrCh).String() + "/pid"
+	getPid := func() int {
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		pid, err := strconv.Atoi(string(body))
+		require.NoError(t, err)
+		return pid
+	}
+	assert.Equal(t, os.Getpid(), getPid())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, server.Upgrade(ctx))
+	require.NoError(t, server.Shutdown(ctx))
+	assert.Equal(t, http.ErrServerClosed, <-served)
+
+	// 同一个端口，现在由子进程处理
+	http.DefaultClient.CloseIdleConnections()
+	assert.Equal(t, cmd.Process.Pid, getPid())
+}
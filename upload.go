@@ -0,0 +1,297 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultMultipartMemory 和 net/http 的 defaultMaxMemory 一致
+const defaultMultipartMemory = 32 << 20
+
+var (
+	// ErrUploadTooLarge 单个文件或者整个请求超过了限制，同时会设置 413
+	ErrUploadTooLarge = errors.New("web: 上传的文件太大")
+	// ErrUploadTypeNotAllowed 嗅探出来的文件类型不在允许的范围内，同时会设置 415
+	ErrUploadTypeNotAllowed = errors.New("web: 不允许上传的文件类型")
+	// ErrMissingFile 表单里面没有这个文件
+	ErrMissingFile = errors.New("web: 上传的文件不存在")
+)
+
+// UploadLimits 是 multipart 上传的限制，通过 ServerWithUploadLimits 设置
+// 所有的零值都表示不限制
+type UploadLimits struct {
+	// MaxFileSize 单个文件的最大字节数
+	MaxFileSize int64
+	// MaxTotalSize 整个请求体的最大字节数
+	MaxTotalSize int64
+	// AllowedTypes 允许的 MIME 类型，支持 image/* 的形式
+	// 类型是根据文件内容嗅探出来的，不相信客户端传过来的 Content-Type
+	AllowedTypes []string
+	// TempDir 超出内存部分的文件写到这个目录，默认是 os.TempDir()
+	TempDir string
+}
+
+func ServerWithUploadLimits(limits UploadLimits) HTTPServerOption {
+	return func(server *HttpServer) {
+		server.uploadLimits = limits
+	}
+}
+
+// MultipartForm 是解析好的 multipart 表单
+// 请求结束之后，写到临时目录里面的文件会被删除
+type MultipartForm struct {
+	Value map[string][]string
+	File  map[string][]*UploadedFile
+}
+
+// RemoveAll 删除所有的临时文件
+func (f *MultipartForm) RemoveAll() error {
+	var err error
+	for _, fhs := range f.File {
+		for _, fh := range fhs {
+			if fh.tmpfile == "" {
+				continue
+			}
+			if e := os.Remove(fh.tmpfile); e != nil && !errors.Is(e, os.ErrNotExist) && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// UploadedFile 是上传的一个文件，小文件在内存里，大文件在临时目录里
+type UploadedFile struct {
+	Filename string
+	Header   textproto.MIMEHeader
+	Size     int64
+	// ContentType 是根据文件内容嗅探出来的类型
+	ContentType string
+
+	content []byte
+	tmpfile string
+}
+
+func (f *UploadedFile) Open() (multipart.File, error) {
+	if f.tmpfile != "" {
+		return os.Open(f.tmpfile)
+	}
+	return sectionReadCloser{io.NewSectionReader(bytes.NewReader(f.content), 0, int64(len(f.content)))}, nil
+}
+
+type sectionReadCloser struct {
+	*io.SectionReader
+}
+
+func (sectionReadCloser) Close() error {
+	return nil
+}
+
+// FormFile 返回表单里面 name 对应的第一个文件
+func (c *Context) FormFile(name string) (*UploadedFile, error) {
+	form, err := c.MultipartForm(defaultMultipartMemory)
+	if err != nil {
+		return nil, err
+	}
+	fhs := form.File[name]
+	if len(fhs) == 0 {
+		return nil, ErrMissingFile
+	}
+	return fhs[0], nil
+}
+
+// RemoveUploadedFiles 删除 MultipartForm 写到临时目录的文件，HttpServer 在请求结束之后会调用
+func (c *Context) RemoveUploadedFiles() error {
+	if c.multipartForm == nil {
+		return nil
+	}
+	err := c.multipartForm.RemoveAll()
+	c.multipartForm = nil
+	return err
+}
+
+// MultipartForm 流式解析 multipart 表单，只会解析一次
+// 普通字段和文件加起来最多占用 maxMemory 的内存，超出的文件写到 UploadLimits.TempDir
+// 超过大小限制时设置 413 并返回 ErrUploadTooLarge，文件类型不允许时设置 415 并返回 ErrUploadTypeNotAllowed
+func (c *Context) MultipartForm(maxMemory int64) (*MultipartForm, error) {
+	if c.multipartForm != nil {
+		return c.multipartForm, nil
+	}
+	var limits UploadLimits
+	if c.uploadLimits != nil {
+		limits = *c.uploadLimits
+	}
+	if limits.MaxTotalSize > 0 {
+		c.Req.Body = http.MaxBytesReader(c.Resp, c.Req.Body, limits.MaxTotalSize)
+	}
+	mr, err := c.Req.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	form := &MultipartForm{
+		Value: make(map[string][]string),
+		File:  make(map[string][]*UploadedFile),
+	}
+	remaining := maxMemory
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, c.uploadFailed(form, err)
+		}
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+		if part.FileName() == "" {
+			var buf bytes.Buffer
+			n, err := io.CopyN(&buf, part, remaining+1)
+			if err != nil && err != io.EOF {
+				return nil, c.uploadFailed(form, err)
+			}
+			if n > remaining {
+				return nil, c.uploadFailed(form, ErrUploadTooLarge)
+			}
+			remaining -= n
+			form.Value[name] = append(form.Value[name], buf.String())
+			continue
+		}
+		fh, err := readUploadedFile(part, limits, &remaining)
+		if fh != nil {
+			// 先放进去，出错的时候 RemoveAll 才能删掉临时文件
+			form.File[name] = append(form.File[name], fh)
+		}
+		if err != nil {
+			return nil, c.uploadFailed(form, err)
+		}
+	}
+	c.multipartForm = form
+	return form, nil
+}
+
+// SaveUploadedFile 把上传的文件保存到 dst
+func (c *Context) SaveUploadedFile(fh *UploadedFile, dst string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, src)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (c *Context) uploadFailed(form *MultipartForm, err error) error {
+	_ = form.RemoveAll()
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		err = ErrUploadTooLarge
+	}
+	switch err {
+	case ErrUploadTooLarge:
+		c.RespStatusCode = http.StatusRequestEntityTooLarge
+		c.RespData = []byte(http.StatusText(http.StatusRequestEntityTooLarge))
+	case ErrUploadTypeNotAllowed:
+		c.RespStatusCode = http.StatusUnsupportedMediaType
+		c.RespData = []byte(http.StatusText(http.StatusUnsupportedMediaType))
+	}
+	return err
+}
+
+func readUploadedFile(part *multipart.Part, limits UploadLimits, remaining *int64) (*UploadedFile, error) {
+	fh := &UploadedFile{
+		Filename: part.FileName(),
+		Header:   part.Header,
+	}
+
+	// 先读 512 字节嗅探类型，和 http.DetectContentType 一致
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(part, sniff)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	sniff = sniff[:n]
+	fh.ContentType = http.DetectContentType(sniff)
+	if !typeAllowed(fh.ContentType, limits.AllowedTypes) {
+		return nil, ErrUploadTypeNotAllowed
+	}
+
+	var r io.Reader = io.MultiReader(bytes.NewReader(sniff), part)
+	if limits.MaxFileSize > 0 {
+		r = io.LimitReader(r, limits.MaxFileSize+1)
+	}
+
+	// 内存放得下就放内存，放不下再写临时文件
+	var buf bytes.Buffer
+	size, err := io.CopyN(&buf, r, *remaining+1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if size <= *remaining {
+		if limits.MaxFileSize > 0 && size > limits.MaxFileSize {
+			return nil, ErrUploadTooLarge
+		}
+		*remaining -= size
+		fh.content = buf.Bytes()
+		fh.Size = size
+		return fh, nil
+	}
+
+	file, err := os.CreateTemp(limits.TempDir, "web-upload-")
+	if err != nil {
+		return nil, err
+	}
+	fh.tmpfile = file.Name()
+	size, err = io.Copy(file, io.MultiReader(&buf, r))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fh, err
+	}
+	if limits.MaxFileSize > 0 && size > limits.MaxFileSize {
+		return fh, ErrUploadTooLarge
+	}
+	fh.Size = size
+	return fh, nil
+}
+
+func typeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		if a == mediaType || a == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(a, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(a, "*")) {
+			return true
+		}
+	}
+	return false
+}
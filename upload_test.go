@@ -0,0 +1,136 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pngHeader = []byte("\x89PNG\x0D\x0A\x1A\x0A")
+
+func newMultipartRequest(t *testing.T, fields map[string]string, files map[string][]byte) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for name, val := range fields {
+		require.NoError(t, w.WriteField(name, val))
+	}
+	for name, data := range files {
+		fw, err := w.CreateFormFile(name, name+".bin")
+		require.NoError(t, err)
+		_, err = fw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func TestContext_MultipartForm(t *testing.T) {
+	tmpDir := t.TempDir()
+	small := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte("a"), 100)...)
+	large := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte("b"), 2048)...)
+
+	testCases := []struct {
+		name      string
+		limits    UploadLimits
+		maxMemory int64
+		files     map[string][]byte
+		wantCode  int
+		wantErr   error
+		// 文件是否写到了临时目录
+		wantTmp bool
+	}{
+		{
+			name:      "in memory",
+			limits:    UploadLimits{TempDir: tmpDir, AllowedTypes: []string{"image/*"}},
+			maxMemory: 1024,
+			files:     map[string][]byte{"avatar": small},
+		},
+		{
+			name:      "stream to disk",
+			limits:    UploadLimits{TempDir: tmpDir},
+			maxMemory: 1024,
+			files:     map[string][]byte{"avatar": large},
+			wantTmp:   true,
+		},
+		{
+			name:      "file too large",
+			limits:    UploadLimits{TempDir: tmpDir, MaxFileSize: 1024},
+			maxMemory: 1024,
+			files:     map[string][]byte{"avatar": large},
+			wantCode:  http.StatusRequestEntityTooLarge,
+			wantErr:   ErrUploadTooLarge,
+		},
+		{
+			name:      "request too large",
+			limits:    UploadLimits{TempDir: tmpDir, MaxTotalSize: 1024},
+			maxMemory: 1 << 20,
+			files:     map[string][]byte{"avatar": large},
+			wantCode:  http.StatusRequestEntityTooLarge,
+			wantErr:   ErrUploadTooLarge,
+		},
+		{
+			name:      "type not allowed",
+			limits:    UploadLimits{TempDir: tmpDir, AllowedTypes: []string{"application/pdf"}},
+			maxMemory: 1024,
+			files:     map[string][]byte{"avatar": small},
+			wantCode:  http.StatusUnsupportedMediaType,
+			wantErr:   ErrUploadTypeNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newMultipartRequest(t, map[string]string{"name": "Tom"}, tc.files)
+			ctx := &Context{Req: req, Resp: httptest.NewRecorder(), uploadLimits: &tc.limits}
+			form, err := ctx.MultipartForm(tc.maxMemory)
+			assert.Equal(t, tc.wantCode, ctx.RespStatusCode)
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+				// 出错的时候临时文件要被清理掉
+				entries, err := os.ReadDir(tmpDir)
+				require.NoError(t, err)
+				assert.Empty(t, entries)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []string{"Tom"}, form.Value["name"])
+
+			fh, err := ctx.FormFile("avatar")
+			require.NoError(t, err)
+			assert.Equal(t, "image/png", fh.ContentType)
+			assert.Equal(t, int64(len(tc.files["avatar"])), fh.Size)
+			assert.Equal(t, tc.wantTmp, fh.tmpfile != "")
+
+			dst := filepath.Join(t.TempDir(), "a", "avatar.png")
+			require.NoError(t, ctx.SaveUploadedFile(fh, dst))
+			data, err := os.ReadFile(dst)
+			require.NoError(t, err)
+			assert.Equal(t, tc.files["avatar"], data)
+
+			f, err := fh.Open()
+			require.NoError(t, err)
+			data, err = io.ReadAll(f)
+			require.NoError(t, err)
+			assert.Equal(t, tc.files["avatar"], data)
+			require.NoError(t, f.Close())
+
+			_, err = ctx.FormFile("not_exist")
+			assert.Equal(t, ErrMissingFile, err)
+
+			require.NoError(t, form.RemoveAll())
+			entries, err := os.ReadDir(tmpDir)
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
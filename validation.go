@@ -0,0 +1,321 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ValidateFunc 校验一个字段，param 是规则里面 = 后面的部分，例如 min=1 里面的 1
+// 返回 false 表示校验失败
+type ValidateFunc func(field reflect.Value, param string) bool
+
+var (
+	validatorsMutex sync.RWMutex
+	validators      = map[string]ValidateFunc{
+		"required": validateRequired,
+		"min":      validateMin,
+		"max":      validateMax,
+		"len":      validateLen,
+		"email":    validateEmail,
+		"oneof":    validateOneOf,
+	}
+
+	emailRegexp = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
+)
+
+// RegisterValidator 注册自定义的校验规则，同名的规则会被覆盖
+// 注册之后就可以在 validate 标签里面使用，例如 validate:"required,phone"
+func RegisterValidator(name string, fn ValidateFunc) {
+	if name == "" || strings.ContainsAny(name, ",=") {
+		panic(fmt.Sprintf("web: 非法的校验规则名字 [%s]", name))
+	}
+	if fn == nil {
+		panic("web: 校验函数不能为 nil")
+	}
+	validatorsMutex.Lock()
+	defer validatorsMutex.Unlock()
+	validators[name] = fn
+}
+
+// ValidationError 是一个字段没有通过的校验规则
+type ValidationError struct {
+	// Field 优先使用 json 标签里面的名字，嵌套的结构体用 . 连接
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+	// Message 是可以直接返回给前端的提示
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("web: 字段 %s 校验失败, %s", e.Field, e.Message)
+}
+
+// ValidationErrors 汇总了所有没有通过校验的字段
+// 可以使用 Context.RespValidationErrors 渲染成 400 响应
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, ve := range e {
+		msgs = append(msgs, ve.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// RespValidationErrors 返回 400，响应体里面带上每个字段的提示
+func (c *Context) RespValidationErrors(errs ValidationErrors) error {
+	return c.RespJson(http.StatusBadRequest, validationResp{
+		Msg:    "参数错误",
+		Errors: errs,
+	})
+}
+
+type validationResp struct {
+	Msg    string           `json:"msg"`
+	Errors ValidationErrors `json:"errors"`
+}
+
+// Validate 按照 validate 标签校验结构体，规则之间用 , 分隔
+// 例如 validate:"required,min=1,max=100,email,oneof=a b c"
+// 字段为零值并且带有 omitempty 的时候，会跳过其余规则
+// 校验失败返回 ValidationErrors，val 不是结构体时什么也不做
+func Validate(val any) error {
+	rv := reflect.ValueOf(val)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	if err := validateStruct(rv, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(rv reflect.Value, prefix string, errs *ValidationErrors) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		fd := rt.Field(i)
+		if !fd.IsExported() && !fd.Anonymous {
+			continue
+		}
+		fv := rv.Field(i)
+		name := prefix + fieldName(fd)
+		if tag, ok := fd.Tag.Lookup("validate"); ok && tag != "-" {
+			if err := validateField(fv, name, tag, errs); err != nil {
+				return err
+			}
+		}
+
+		// 嵌套的结构体继续校验
+		for fv.Kind() == reflect.Pointer && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() != reflect.Struct || fv.Type() == timeType {
+			continue
+		}
+		nestedPrefix := name + "."
+		if fd.Anonymous {
+			nestedPrefix = prefix
+		}
+		if err := validateStruct(fv, nestedPrefix, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateField(fv reflect.Value, name string, tag string, errs *ValidationErrors) error {
+	rules := strings.Split(tag, ",")
+	for _, rule := range rules {
+		if rule == "omitempty" && fv.IsZero() {
+			return nil
+		}
+	}
+	for _, rule := range rules {
+		if rule == "" || rule == "omitempty" {
+			continue
+		}
+		ruleName, param, _ := strings.Cut(rule, "=")
+		validatorsMutex.RLock()
+		fn, ok := validators[ruleName]
+		validatorsMutex.RUnlock()
+		if !ok {
+			return fmt.Errorf("web: 未知的校验规则 [%s]", ruleName)
+		}
+
+		target := fv
+		if ruleName != "required" {
+			// 除了 required，其余规则作用在指针指向的值上，nil 指针直接跳过
+			for target.Kind() == reflect.Pointer {
+				if target.IsNil() {
+					break
+				}
+				target = target.Elem()
+			}
+			if target.Kind() == reflect.Pointer {
+				continue
+			}
+		}
+		if !fn(target, param) {
+			*errs = append(*errs, &ValidationError{
+				Field:   name,
+				Rule:    ruleName,
+				Param:   param,
+				Message: validationMessage(target, ruleName, param),
+			})
+			// 一个字段只报告第一个没有通过的规则
+			return nil
+		}
+	}
+	return nil
+}
+
+// fieldName 优先使用 json 标签，这样提示里面的名字和前端看到的一致
+func fieldName(fd reflect.StructField) string {
+	if name := strings.Split(fd.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	return fd.Name
+}
+
+func validationMessage(fv reflect.Value, rule string, param string) string {
+	isLen := fv.Kind() == reflect.String || fv.Kind() == reflect.Slice ||
+		fv.Kind() == reflect.Map || fv.Kind() == reflect.Array
+	switch rule {
+	case "required":
+		return "不能为空"
+	case "min":
+		if isLen {
+			return fmt.Sprintf("长度不能小于 %s", param)
+		}
+		return fmt.Sprintf("不能小于 %s", param)
+	case "max":
+		if isLen {
+			return fmt.Sprintf("长度不能大于 %s", param)
+		}
+		return fmt.Sprintf("不能大于 %s", param)
+	case "len":
+		return fmt.Sprintf("长度必须是 %s", param)
+	case "email":
+		return "不是合法的邮箱"
+	case "oneof":
+		return fmt.Sprintf("必须是 [%s] 中的一个", param)
+	}
+	return fmt.Sprintf("没有通过 %s 校验", rule)
+}
+
+func validateRequired(fv reflect.Value, _ string) bool {
+	return !fv.IsZero()
+}
+
+func validateMin(fv reflect.Value, param string) bool {
+	cmp, ok := compareWithParam(fv, param)
+	return ok && cmp >= 0
+}
+
+func validateMax(fv reflect.Value, param string) bool {
+	cmp, ok := compareWithParam(fv, param)
+	return ok && cmp <= 0
+}
+
+func validateLen(fv reflect.Value, param string) bool {
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		return false
+	}
+	l, ok := lengthOf(fv)
+	return ok && l == n
+}
+
+func validateEmail(fv reflect.Value, _ string) bool {
+	return fv.Kind() == reflect.String && emailRegexp.MatchString(fv.String())
+}
+
+func validateOneOf(fv reflect.Value, param string) bool {
+	var val string
+	switch fv.Kind() {
+	case reflect.String:
+		val = fv.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		val = strconv.FormatInt(fv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		val = strconv.FormatUint(fv.Uint(), 10)
+	default:
+		return false
+	}
+	for _, opt := range strings.Fields(param) {
+		if opt == val {
+			return true
+		}
+	}
+	return false
+}
+
+// compareWithParam 数字比较值，字符串、切片、map 比较长度
+// 第二个返回值为 false 表示这个类型不支持比较或者 param 不合法
+func compareWithParam(fv reflect.Value, param string) (int, bool) {
+	if l, ok := lengthOf(fv); ok {
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return 0, false
+		}
+		return compare(float64(l), float64(n)), true
+	}
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return compare(float64(fv.Int()), float64(n)), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return compare(float64(fv.Uint()), float64(n)), true
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return 0, false
+		}
+		return compare(fv.Float(), n), true
+	}
+	return 0, false
+}
+
+func lengthOf(fv reflect.Value) (int, bool) {
+	switch fv.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(fv.String()), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return fv.Len(), true
+	}
+	return 0, false
+}
+
+func compare(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,101 @@
+package web
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type validateAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type validateUser struct {
+	Name    string           `json:"name" validate:"required,min=2,max=5"`
+	Age     int              `json:"age" validate:"min=1,max=100"`
+	Email   string           `json:"email" validate:"omitempty,email"`
+	Role    string           `json:"role" validate:"oneof=admin user"`
+	Tags    []string         `json:"tags" validate:"max=2"`
+	Nick    *string          `json:"nick" validate:"min=3"`
+	Phone   string           `json:"phone" validate:"omitempty,phone"`
+	Address *validateAddress `json:"address"`
+	Home    validateAddress
+}
+
+func TestValidate(t *testing.T) {
+	RegisterValidator("phone", func(field reflect.Value, param string) bool {
+		return len(field.String()) == 11
+	})
+	short := "a"
+	testCases := []struct {
+		name     string
+		val      any
+		wantErrs ValidationErrors
+	}{
+		{
+			name: "pass",
+			val: &validateUser{Name: "Tom", Age: 18, Role: "admin", Phone: "13800000000",
+				Home: validateAddress{City: "beijing"}},
+		},
+		{
+			name: "not struct",
+			val:  map[string]string{},
+		},
+		{
+			name: "all failed",
+			val: &validateUser{Name: "T", Age: 101, Email: "tom", Role: "root",
+				Tags: []string{"a", "b", "c"}, Nick: &short, Phone: "138",
+				Address: &validateAddress{}},
+			wantErrs: ValidationErrors{
+				{Field: "name", Rule: "min", Param: "2", Message: "长度不能小于 2"},
+				{Field: "age", Rule: "max", Param: "100", Message: "不能大于 100"},
+				{Field: "email", Rule: "email", Message: "不是合法的邮箱"},
+				{Field: "role", Rule: "oneof", Param: "admin user", Message: "必须是 [admin user] 中的一个"},
+				{Field: "tags", Rule: "max", Param: "2", Message: "长度不能大于 2"},
+				{Field: "nick", Rule: "min", Param: "3", Message: "长度不能小于 3"},
+				{Field: "phone", Rule: "phone", Message: "没有通过 phone 校验"},
+				{Field: "address.city", Rule: "required", Message: "不能为空"},
+				{Field: "Home.city", Rule: "required", Message: "不能为空"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate(tc.val)
+			if tc.wantErrs == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tc.wantErrs, err)
+		})
+	}
+
+	type unknownRule struct {
+		Name string `validate:"not_exist"`
+	}
+	assert.EqualError(t, Validate(&unknownRule{}), "web: 未知的校验规则 [not_exist]")
+}
+
+func TestContext_BindJson_Validate(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"name":"Tom","age":0,"role":"user"}`))
+	ctx := &Context{Req: req}
+	var u validateUser
+	err := ctx.BindJson(&u)
+	var errs ValidationErrors
+	require.ErrorAs(t, err, &errs)
+
+	require.NoError(t, ctx.RespValidationErrors(errs))
+	assert.Equal(t, http.StatusBadRequest, ctx.RespStatusCode)
+	var buf bytes.Buffer
+	buf.WriteString(`{"msg":"参数错误","errors":[`)
+	buf.WriteString(`{"field":"age","rule":"min","param":"1","message":"不能小于 1"},`)
+	buf.WriteString(`{"field":"Home.city","rule":"required","message":"不能为空"}]}`)
+	assert.Equal(t, buf.String(), string(ctx.RespData))
+}
@@ -0,0 +1,367 @@
+package web
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket 的消息类型，和 RFC 6455 里面的 opcode 一致
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// 关闭连接时的状态码，RFC 6455 7.4.1
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	// websocketGUID 是握手时计算 Sec-WebSocket-Accept 用的固定值
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// defaultReadLimit 默认的单条消息最大字节数
+	defaultReadLimit = 1 << 20
+	// 控制帧的负载不能超过 125 字节
+	maxControlPayload = 125
+)
+
+var (
+	ErrWebSocketHandshake = errors.New("web: 不是合法的 WebSocket 握手请求")
+	ErrMessageTooLarge    = errors.New("web: WebSocket 消息超过了大小限制")
+	ErrWebSocketClosed    = errors.New("web: WebSocket 连接已经关闭")
+)
+
+// CloseError 是对端发送的关闭帧
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("web: WebSocket 连接关闭 %d %s", e.Code, e.Text)
+}
+
+// Upgrade 把当前的请求升级成 WebSocket 连接
+// 这个请求依旧会经过 server 上的 middleware，升级之后 RespStatusCode 是 101
+// 握手失败的时候会设置 400 或者 426，并返回 ErrWebSocketHandshake
+func (c *Context) Upgrade() (*WebSocketConn, error) {
+	req := c.Req
+	if req.Method != http.MethodGet ||
+		!headerContainsToken(req.Header, "Connection", "upgrade") ||
+		!headerContainsToken(req.Header, "Upgrade", "websocket") {
+		c.RespStatusCode = http.StatusBadRequest
+		c.RespData = []byte(http.StatusText(http.StatusBadRequest))
+		return nil, ErrWebSocketHandshake
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.Resp.Header().Set("Sec-WebSocket-Version", "13")
+		c.RespStatusCode = http.StatusUpgradeRequired
+		c.RespData = []byte(http.StatusText(http.StatusUpgradeRequired))
+		return nil, ErrWebSocketHandshake
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		c.RespStatusCode = http.StatusBadRequest
+		c.RespData = []byte(http.StatusText(http.StatusBadRequest))
+		return nil, ErrWebSocketHandshake
+	}
+
+	hj, ok := c.Resp.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("web: ResponseWriter 不支持 Hijack")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	// 握手的响应直接写到连接上
+	var sb strings.Builder
+	sb.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	sb.WriteString("Upgrade: websocket\r\n")
+	sb.WriteString("Connection: Upgrade\r\n")
+	sb.WriteString("Sec-WebSocket-Accept: " + computeAcceptKey(key) + "\r\n")
+	sb.WriteString("\r\n")
+	if _, err = rw.WriteString(sb.String()); err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	c.RespStatusCode = http.StatusSwitchingProtocols
+	return &WebSocketConn{
+		conn:      conn,
+		br:        rw.Reader,
+		readLimit: defaultReadLimit,
+	}, nil
+}
+
+func computeAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContainsToken(header http.Header, name string, token string) bool {
+	for _, val := range header.Values(name) {
+		for _, t := range strings.Split(val, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WebSocketConn 是服务端的 WebSocket 连接
+// 同一时间只能有一个 goroutine 读，写是并发安全的
+type WebSocketConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	readLimit   int64
+	pongHandler func(data []byte)
+
+	writeMutex sync.Mutex
+	closeSent  bool
+}
+
+// SetReadLimit 设置单条消息的最大字节数，分片的消息按照合并之后的大小计算
+// 超过限制会发送 1009 关闭连接
+func (c *WebSocketConn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetPongHandler 设置收到 pong 时的回调，一般用来延长读的超时时间
+func (c *WebSocketConn) SetPongHandler(fn func(data []byte)) {
+	c.pongHandler = fn
+}
+
+func (c *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *WebSocketConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+func (c *WebSocketConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// ReadMessage 读取一条完整的消息，分片会被合并
+// ping 会自动回复 pong；收到关闭帧会回复关闭帧，并返回 *CloseError
+func (c *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	messageType = -1
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case PingMessage:
+			if err = c.writeFrame(PongMessage, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if c.pongHandler != nil {
+				c.pongHandler(payload)
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, c.handleClose(payload)
+		case TextMessage, BinaryMessage:
+			if messageType != -1 {
+				return 0, nil, c.fail(CloseProtocolError, "上一条消息的分片还没有结束")
+			}
+			messageType = opcode
+		case continuationFrame:
+			if messageType == -1 {
+				return 0, nil, c.fail(CloseProtocolError, "没有起始帧的分片")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "未知的 opcode")
+		}
+
+		if c.readLimit > 0 && int64(len(data)+len(payload)) > c.readLimit {
+			_ = c.Close(CloseMessageTooBig, "")
+			return 0, nil, ErrMessageTooLarge
+		}
+		data = append(data, payload...)
+		if !fin {
+			continue
+		}
+		if messageType == TextMessage && !utf8.Valid(data) {
+			return 0, nil, c.fail(CloseInvalidFramePayloadData, "不是合法的 UTF-8")
+		}
+		return messageType, data, nil
+	}
+}
+
+// WriteMessage 发送一条消息，服务端发送的帧不需要掩码
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("web: 不支持的消息类型 %d", messageType)
+	}
+	return c.writeFrame(messageType, data)
+}
+
+func (c *WebSocketConn) Ping(data []byte) error {
+	if len(data) > maxControlPayload {
+		return ErrMessageTooLarge
+	}
+	return c.writeFrame(PingMessage, data)
+}
+
+// Close 发送关闭帧并关闭底层的连接
+func (c *WebSocketConn) Close(code int, reason string) error {
+	var payload []byte
+	if code != CloseNoStatusReceived {
+		payload = make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		payload = append(payload, reason...)
+		if len(payload) > maxControlPayload {
+			payload = payload[:maxControlPayload]
+		}
+	}
+	err := c.writeFrame(CloseMessage, payload)
+	if closeErr := c.conn.Close(); err == nil || err == ErrWebSocketClosed {
+		err = closeErr
+	}
+	return err
+}
+
+func (c *WebSocketConn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatusReceived}
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "关闭帧的负载不合法")
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+		if !utf8.ValidString(closeErr.Text) {
+			return c.fail(CloseInvalidFramePayloadData, "关闭原因不是合法的 UTF-8")
+		}
+	}
+	// 回复同样的状态码，完成关闭握手
+	_ = c.Close(closeErr.Code, "")
+	return closeErr
+}
+
+// fail 因为协议错误关闭连接
+func (c *WebSocketConn) fail(code int, reason string) error {
+	_ = c.Close(code, reason)
+	return &CloseError{Code: code, Text: reason}
+}
+
+// readFrame 读取一帧，客户端发送的帧必须带有掩码
+func (c *WebSocketConn) readFrame() (fin bool, opcode int, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = header[0]&0x80 != 0
+	// 没有协商任何扩展，RSV 必须是 0
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "RSV 不为 0")
+	}
+	opcode = int(header[0] & 0x0f)
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "客户端的帧没有掩码")
+	}
+
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		l := binary.BigEndian.Uint64(ext[:])
+		if l>>63 != 0 {
+			return false, 0, nil, c.fail(CloseProtocolError, "长度的最高位不为 0")
+		}
+		length = int64(l)
+	}
+
+	if opcode >= CloseMessage {
+		if !fin || length > maxControlPayload {
+			return false, 0, nil, c.fail(CloseProtocolError, "控制帧不能分片，负载不能超过 125 字节")
+		}
+	} else if c.readLimit > 0 && length > c.readLimit {
+		// 还没有读负载，提前拒绝，避免分配过大的内存
+		_ = c.Close(CloseMessageTooBig, "")
+		return false, 0, nil, ErrMessageTooLarge
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+func (c *WebSocketConn) writeFrame(opcode int, payload []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if c.closeSent {
+		return ErrWebSocketClosed
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+
+	header := make([]byte, 0, 10+len(payload))
+	header = append(header, 0x80|byte(opcode))
+	switch l := len(payload); {
+	case l <= 125:
+		header = append(header, byte(l))
+	case l <= 0xffff:
+		header = append(header, 126, byte(l>>8), byte(l))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(l))
+	}
+	_, err := c.conn.Write(append(header, payload...))
+	return err
+}
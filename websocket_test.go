@@ -0,0 +1,212 @@
+package web
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeClientFrame 按照客户端的格式写一帧，客户端的帧必须带掩码
+func writeClientFrame(t *testing.T, conn net.Conn, fin bool, opcode byte, payload []byte) {
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	switch l := len(payload); {
+	case l <= 125:
+		frame = append(frame, 0x80|byte(l))
+	case l <= 0xffff:
+		frame = append(frame, 0x80|126, byte(l>>8), byte(l))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(l))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := conn.Write(frame)
+	require.NoError(t, err)
+}
+
+func readServerFrame(t *testing.T, r *bufio.Reader) (byte, []byte) {
+	var header [2]byte
+	_, err := io.ReadFull(r, header[:])
+	require.NoError(t, err)
+	// 服务端的帧不带掩码
+	require.Zero(t, header[1]&0x80)
+	length := int(header[1] & 0x7f)
+	if length == 126 {
+		var ext [2]byte
+		_, err = io.ReadFull(r, ext[:])
+		require.NoError(t, err)
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(r, payload)
+	require.NoError(t, err)
+	return header[0] & 0x0f, payload
+}
+
+func dialWebSocket(t *testing.T, url string) (net.Conn, *bufio.Reader, *http.Response) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("GET /chat HTTP/1.1\r\n" +
+		"Host: localhost\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"))
+	require.NoError(t, err)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	require.NoError(t, err)
+	return conn, br, resp
+}
+
+func TestContext_Upgrade(t *testing.T) {
+	mdlCode := make(chan int, 1)
+	server := NewHTTPServer(ServerWithMiddleware(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			mdlCode <- ctx.RespStatusCode
+		}
+	}))
+	server.Get("/chat", func(ctx *Context) {
+		conn, err := ctx.Upgrade()
+		if err != nil {
+			return
+		}
+		conn.SetReadLimit(1024)
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err = conn.WriteMessage(typ, data); err != nil {
+				return
+			}
+		}
+	})
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	conn, br, resp := dialWebSocket(t, ts.URL)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	// RFC 6455 里面的例子
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+
+	// 普通消息
+	writeClientFrame(t, conn, true, TextMessage, []byte("hello"))
+	typ, payload := readServerFrame(t, br)
+	assert.Equal(t, byte(TextMessage), typ)
+	assert.Equal(t, "hello", string(payload))
+
+	// 分片，中间插入一个 ping
+	writeClientFrame(t, conn, false, BinaryMessage, []byte("ab"))
+	writeClientFrame(t, conn, true, PingMessage, []byte("p"))
+	writeClientFrame(t, conn, true, continuationFrame, []byte("cd"))
+	typ, payload = readServerFrame(t, br)
+	assert.Equal(t, byte(PongMessage), typ)
+	assert.Equal(t, "p", string(payload))
+	typ, payload = readServerFrame(t, br)
+	assert.Equal(t, byte(BinaryMessage), typ)
+	assert.Equal(t, "abcd", string(payload))
+
+	// 超过大小限制
+	writeClientFrame(t, conn, true, TextMessage, []byte(strings.Repeat("a", 1025)))
+	typ, payload = readServerFrame(t, br)
+	assert.Equal(t, byte(CloseMessage), typ)
+	assert.Equal(t, uint16(CloseMessageTooBig), binary.BigEndian.Uint16(payload))
+
+	// 升级的请求也会经过 middleware
+	select {
+	case code := <-mdlCode:
+		assert.Equal(t, http.StatusSwitchingProtocols, code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("middleware 没有执行")
+	}
+}
+
+func TestContext_Upgrade_Close(t *testing.T) {
+	closeErr := make(chan error, 1)
+	server := NewHTTPServer()
+	server.Get("/chat", func(ctx *Context) {
+		conn, err := ctx.Upgrade()
+		if err != nil {
+			return
+		}
+		_, _, err = conn.ReadMessage()
+		closeErr <- err
+	})
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	conn, br, _ := dialWebSocket(t, ts.URL)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	payload := []byte{0x03, 0xe8}
+	payload = append(payload, "bye"...)
+	writeClientFrame(t, conn, true, CloseMessage, payload)
+
+	// 服务端回复同样的状态码
+	typ, reply := readServerFrame(t, br)
+	assert.Equal(t, byte(CloseMessage), typ)
+	assert.Equal(t, uint16(CloseNormalClosure), binary.BigEndian.Uint16(reply))
+	assert.Equal(t, &CloseError{Code: CloseNormalClosure, Text: "bye"}, <-closeErr)
+}
+
+func TestContext_Upgrade_BadRequest(t *testing.T) {
+	testCases := []struct {
+		name     string
+		header   map[string]string
+		wantCode int
+	}{
+		{
+			name:     "not upgrade",
+			header:   map[string]string{},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "version",
+			header: map[string]string{
+				"Connection": "Upgrade", "Upgrade": "websocket",
+				"Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ==", "Sec-WebSocket-Version": "8",
+			},
+			wantCode: http.StatusUpgradeRequired,
+		},
+		{
+			name: "key",
+			header: map[string]string{
+				"Connection": "Upgrade", "Upgrade": "websocket",
+				"Sec-WebSocket-Key": "abc", "Sec-WebSocket-Version": "13",
+			},
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/chat", nil)
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			ctx := &Context{Req: req, Resp: httptest.NewRecorder()}
+			_, err := ctx.Upgrade()
+			assert.Equal(t, ErrWebSocketHandshake, err)
+			assert.Equal(t, tc.wantCode, ctx.RespStatusCode)
+		})
+	}
+}
@@ -0,0 +1,230 @@
+// Package webtest 不监听端口，直接在内存里面用 HttpServer 处理请求，用来测试 handler 和 middleware
+//
+//	webtest.GET("/user/1").Header("X-Request-ID", "abc").Do(server).
+//		AssertStatus(t, http.StatusOK).
+//		AssertMatchedRoute(t, "/user/:id").
+//		AssertJSONPath(t, "data.name", "Tom")
+package webtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"web"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Request 是构造请求的 builder，构造过程中的错误会在 Do 的时候报告
+type Request struct {
+	method string
+	path   string
+	header http.Header
+	query  url.Values
+	body   []byte
+	err    error
+}
+
+func NewRequest(method, path string) *Request {
+	return &Request{
+		method: method,
+		path:   path,
+		header: http.Header{},
+		query:  url.Values{},
+	}
+}
+
+func GET(path string) *Request {
+	return NewRequest(http.MethodGet, path)
+}
+
+func POST(path string) *Request {
+	return NewRequest(http.MethodPost, path)
+}
+
+func PUT(path string) *Request {
+	return NewRequest(http.MethodPut, path)
+}
+
+func PATCH(path string) *Request {
+	return NewRequest(http.MethodPatch, path)
+}
+
+func DELETE(path string) *Request {
+	return NewRequest(http.MethodDelete, path)
+}
+
+// Header 添加一个请求头，同名的会追加
+func (r *Request) Header(key, value string) *Request {
+	r.header.Add(key, value)
+	return r
+}
+
+// Query 添加一个查询参数，和 path 里面已经有的查询参数合并
+func (r *Request) Query(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+// Body 设置原始的请求体，Content-Type 需要自己设置
+func (r *Request) Body(body []byte) *Request {
+	r.body = body
+	return r
+}
+
+// JSON 把 val 序列化成请求体，Content-Type 是 application/json
+func (r *Request) JSON(val any) *Request {
+	r.body, r.err = json.Marshal(val)
+	r.header.Set("Content-Type", "application/json")
+	return r
+}
+
+// Form 把 form 编码成请求体，Content-Type 是 application/x-www-form-urlencoded
+func (r *Request) Form(form url.Values) *Request {
+	r.body = []byte(form.Encode())
+	r.header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+// Build 生成 *http.Request，可以直接交给别的 http.Handler
+func (r *Request) Build() (*http.Request, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	target := r.path
+	if len(r.query) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + r.query.Encode()
+	}
+	req := httptest.NewRequest(r.method, target, bytes.NewReader(r.body))
+	for key, values := range r.header {
+		req.Header[key] = values
+	}
+	return req, nil
+}
+
+// Do 用 server 处理请求，不会监听端口，也不会经过网络
+// 请求构造失败的时候会 panic，这属于测试代码自己的问题
+func (r *Request) Do(server *web.HttpServer) *Response {
+	req, err := r.Build()
+	if err != nil {
+		panic(fmt.Sprintf("webtest: 构造请求失败: %v", err))
+	}
+	recorder := httptest.NewRecorder()
+	ctx := server.ServeContext(recorder, req)
+	return &Response{
+		Recorder:     recorder,
+		MatchedRoute: ctx.MatchedRoute,
+		PathParams:   ctx.PathParams,
+		TimedOut:     ctx.TimedOut,
+	}
+}
+
+// Response 是处理的结果，除了响应本身，还记录了 Context 上面路由相关的信息
+// Assert 开头的方法失败的时候调用 t.Errorf，返回 Response 本身，所以可以串起来用
+type Response struct {
+	Recorder     *httptest.ResponseRecorder
+	MatchedRoute string
+	PathParams   map[string]string
+	TimedOut     bool
+}
+
+func (r *Response) StatusCode() int {
+	return r.Recorder.Code
+}
+
+func (r *Response) Header() http.Header {
+	return r.Recorder.Header()
+}
+
+func (r *Response) Body() []byte {
+	return r.Recorder.Body.Bytes()
+}
+
+// DecodeJSON 把响应体反序列化到 val
+func (r *Response) DecodeJSON(val any) error {
+	return json.Unmarshal(r.Body(), val)
+}
+
+func (r *Response) AssertStatus(t testing.TB, status int) *Response {
+	t.Helper()
+	assert.Equal(t, status, r.StatusCode(), "状态码不对，响应: %s", r.Body())
+	return r
+}
+
+func (r *Response) AssertHeader(t testing.TB, key, value string) *Response {
+	t.Helper()
+	assert.Equal(t, value, r.Header().Get(key), "响应头 %s 不对", key)
+	return r
+}
+
+func (r *Response) AssertBody(t testing.TB, body string) *Response {
+	t.Helper()
+	assert.Equal(t, body, string(r.Body()))
+	return r
+}
+
+func (r *Response) AssertMatchedRoute(t testing.TB, route string) *Response {
+	t.Helper()
+	assert.Equal(t, route, r.MatchedRoute)
+	return r
+}
+
+// AssertJSONPath 检查响应体里面 path 的值，path 用 . 分隔，数组用下标，例如 data.items.0.name
+// 比较之前 want 会先经过一次 JSON 序列化，所以 int 和 float64 之类的可以直接比较
+func (r *Response) AssertJSONPath(t testing.TB, path string, want any) *Response {
+	t.Helper()
+	got, err := r.JSONPath(path)
+	if !assert.NoError(t, err) {
+		return r
+	}
+	data, err := json.Marshal(want)
+	if !assert.NoError(t, err) {
+		return r
+	}
+	var normalized any
+	if !assert.NoError(t, json.Unmarshal(data, &normalized)) {
+		return r
+	}
+	assert.Equal(t, normalized, got, "JSON 路径 %s 的值不对", path)
+	return r
+}
+
+// JSONPath 返回响应体里面 path 的值，规则见 AssertJSONPath
+func (r *Response) JSONPath(path string) (any, error) {
+	var cur any
+	if err := r.DecodeJSON(&cur); err != nil {
+		return nil, fmt.Errorf("webtest: 响应体不是 JSON: %w", err)
+	}
+	if path == "" {
+		return cur, nil
+	}
+	for _, seg := range strings.Split(path, ".") {
+		switch val := cur.(type) {
+		case map[string]any:
+			next, ok := val[seg]
+			if !ok {
+				return nil, fmt.Errorf("webtest: JSON 路径 %s 中的 %s 不存在", path, seg)
+			}
+			cur = next
+		case []any:
+			idx, err := strconv.Atoi(seg)
+			if err != nil || idx < 0 || idx >= len(val) {
+				return nil, fmt.Errorf("webtest: JSON 路径 %s 中的下标 %s 不合法", path, seg)
+			}
+			cur = val[idx]
+		default:
+			return nil, fmt.Errorf("webtest: JSON 路径 %s 中的 %s 不是对象或者数组", path, seg)
+		}
+	}
+	return cur, nil
+}
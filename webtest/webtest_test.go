@@ -0,0 +1,128 @@
+package webtest
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"web"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type User struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func newTestServer() *web.HttpServer {
+	server := web.NewHTTPServer()
+	server.Get("/user/detail", func(ctx *web.Context) {
+		name, _ := ctx.QueryValue("name")
+		ctx.Resp.Header().Set("X-Request-ID", ctx.Req.Header.Get("X-Request-ID"))
+		_ = ctx.RespJsonOK(map[string]any{
+			"data": map[string]any{
+				"name": name,
+				"tags": []string{"a", "b"},
+			},
+		})
+	})
+	server.Get("/user/:id", func(ctx *web.Context) {
+		_ = ctx.RespJsonOK(map[string]string{"id": ctx.PathParams["id"]})
+	})
+	server.POST("/user", func(ctx *web.Context) {
+		var u User
+		if err := ctx.BindJson(&u); err != nil {
+			ctx.RespStatusCode = http.StatusBadRequest
+			return
+		}
+		_ = ctx.RespJson(http.StatusCreated, u)
+	})
+	server.POST("/login", func(ctx *web.Context) {
+		name, _ := ctx.FormValue("name")
+		ctx.RespStatusCode = http.StatusOK
+		ctx.RespData = []byte("hello " + name)
+	})
+	return server
+}
+
+func TestRequest_Do(t *testing.T) {
+	server := newTestServer()
+
+	resp := GET("/user/detail").
+		Query("name", "Tom").
+		Header("X-Request-ID", "abc").
+		Do(server).
+		AssertStatus(t, http.StatusOK).
+		AssertHeader(t, "X-Request-ID", "abc").
+		AssertJSONPath(t, "data.name", "Tom").
+		AssertJSONPath(t, "data.tags.1", "b").
+		AssertJSONPath(t, "data.tags", []string{"a", "b"})
+	assert.Equal(t, "/user/detail", resp.MatchedRoute)
+
+	// MatchedRoute 是注册的路由，而不是请求的路径
+	GET("/user/12").Do(server).
+		AssertStatus(t, http.StatusOK).
+		AssertMatchedRoute(t, "/user/:id").
+		AssertJSONPath(t, "id", "12")
+
+	POST("/user").JSON(User{Name: "Jerry", Age: 18}).Do(server).
+		AssertStatus(t, http.StatusCreated).
+		AssertJSONPath(t, "age", 18)
+
+	POST("/login").Form(url.Values{"name": {"Tom"}}).Do(server).
+		AssertStatus(t, http.StatusOK).
+		AssertBody(t, "hello Tom")
+
+	resp = GET("/not-exist").Do(server).AssertStatus(t, http.StatusNotFound)
+	assert.Empty(t, resp.MatchedRoute)
+}
+
+func TestResponse_JSONPath(t *testing.T) {
+	server := newTestServer()
+	resp := GET("/user/detail?name=Tom").Do(server)
+
+	testCases := []struct {
+		name    string
+		path    string
+		want    any
+		wantErr string
+	}{
+		{
+			name: "object",
+			path: "data.name",
+			want: "Tom",
+		},
+		{
+			name: "array",
+			path: "data.tags.0",
+			want: "a",
+		},
+		{
+			name:    "missing key",
+			path:    "data.age",
+			wantErr: "webtest: JSON 路径 data.age 中的 age 不存在",
+		},
+		{
+			name:    "bad index",
+			path:    "data.tags.2",
+			wantErr: "webtest: JSON 路径 data.tags.2 中的下标 2 不合法",
+		},
+		{
+			name:    "not container",
+			path:    "data.name.first",
+			wantErr: "webtest: JSON 路径 data.name.first 中的 first 不是对象或者数组",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := resp.JSONPath(tc.path)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}